@@ -55,6 +55,7 @@ type GeneralOpenAIRequest struct {
 	ParallelToolCalls    *bool             `json:"parallel_tool_calls,omitempty"`
 	Tools               []ToolCallRequest `json:"tools,omitempty"`
 	ToolChoice          any               `json:"tool_choice,omitempty"`
+	MaxToolCalls        *uint             `json:"max_tool_calls,omitempty"`
 	FunctionCall        json.RawMessage   `json:"function_call,omitempty"`
 	User                json.RawMessage   `json:"user,omitempty"`
 	// ServiceTier specifies upstream service level and may affect billing.
@@ -78,6 +79,9 @@ type GeneralOpenAIRequest struct {
 	LogitBias            json.RawMessage `json:"logit_bias,omitempty"`
 	Metadata             json.RawMessage `json:"metadata,omitempty"`
 	Prediction           json.RawMessage `json:"prediction,omitempty"`
+	// Truncation ("auto" or "disabled") carries the Responses truncation
+	// strategy for upstreams that auto-truncate overflowing context.
+	Truncation json.RawMessage `json:"truncation,omitempty"`
 	// gemini
 	ExtraBody json.RawMessage `json:"extra_body,omitempty"`
 	//xai
@@ -233,11 +237,50 @@ type ToolCallRequest struct {
 	Custom   json.RawMessage `json:"custom,omitempty"`
 }
 
+// GetCustomTool returns the typed definition of a "custom" tool, or nil when
+// the tool is not a custom tool or Custom cannot be decoded.
+func (t *ToolCallRequest) GetCustomTool() *CustomToolRequest {
+	if t.Type != "custom" || len(t.Custom) == 0 {
+		return nil
+	}
+	var custom CustomToolRequest
+	if err := common.Unmarshal(t.Custom, &custom); err != nil {
+		return nil
+	}
+	return &custom
+}
+
+func (t *ToolCallRequest) SetCustomTool(custom CustomToolRequest) {
+	t.Type = "custom"
+	t.Custom, _ = common.Marshal(custom)
+}
+
+// CustomToolRequest is a free-form input tool, optionally constrained by a
+// grammar. It is carried in ToolCallRequest.Custom.
+type CustomToolRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Format      *CustomToolFormat `json:"format,omitempty"`
+}
+
+type CustomToolFormat struct {
+	// Type is "text" or "grammar"
+	Type    string             `json:"type"`
+	Grammar *CustomToolGrammar `json:"grammar,omitempty"`
+}
+
+type CustomToolGrammar struct {
+	// Syntax is "lark" or "regex"
+	Syntax     string `json:"syntax"`
+	Definition string `json:"definition"`
+}
+
 type FunctionRequest struct {
 	Description string `json:"description,omitempty"`
 	Name        string `json:"name"`
 	Parameters  any    `json:"parameters,omitempty"`
 	Arguments   string `json:"arguments,omitempty"`
+	Strict      *bool  `json:"strict,omitempty"`
 }
 
 type StreamOptions struct {
@@ -283,10 +326,62 @@ type Message struct {
 	Reasoning        string          `json:"reasoning,omitempty"`
 	ToolCalls        json.RawMessage `json:"tool_calls,omitempty"`
 	ToolCallId       string          `json:"tool_call_id,omitempty"`
-	parsedContent    []MediaContent
+	// Status carries the Responses input item status (completed, in_progress,
+	// incomplete) across conversions. Chat has no such field, so it is never
+	// sent upstream.
+	Status string `json:"-"`
+	// Annotations are only set on assistant messages in responses.
+	Annotations []MessageAnnotation `json:"annotations,omitempty"`
+	// Audio is only set on assistant messages from audio-capable models.
+	Audio *MessageAudio `json:"audio,omitempty"`
+	// Refusal is set on assistant messages when the model declined to answer.
+	Refusal *string `json:"refusal,omitempty"`
+	// Extensions keeps unknown fields of a Responses input item, such as
+	// vendor blocks, across conversions. Chat has no place for them, so they
+	// are never sent upstream.
+	Extensions    map[string]json.RawMessage `json:"-"`
+	parsedContent []MediaContent
 	//parsedStringContent *string
 }
 
+// MessageAudio is the audio output of an assistant message.
+type MessageAudio struct {
+	Id         string `json:"id,omitempty"`
+	Data       string `json:"data,omitempty"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+// MessageAnnotation is a citation attached to an assistant message.
+type MessageAnnotation struct {
+	Type         string               `json:"type"`
+	FileCitation *MessageFileCitation `json:"file_citation,omitempty"`
+	// ContainerFileCitation cites a file written by code interpreter.
+	ContainerFileCitation *MessageContainerFileCitation `json:"container_file_citation,omitempty"`
+	// URLCitation cites a web page used by a search-augmented model.
+	URLCitation *MessageURLCitation `json:"url_citation,omitempty"`
+}
+
+type MessageFileCitation struct {
+	FileId   string `json:"file_id"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type MessageContainerFileCitation struct {
+	ContainerId string `json:"container_id"`
+	FileId      string `json:"file_id"`
+	Filename    string `json:"filename,omitempty"`
+	StartIndex  int    `json:"start_index"`
+	EndIndex    int    `json:"end_index"`
+}
+
+type MessageURLCitation struct {
+	Url        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
 type MediaContent struct {
 	Type       string `json:"type"`
 	Text       string `json:"text,omitempty"`
@@ -307,6 +402,7 @@ func (m *MediaContent) GetImageMedia() *MessageImageUrl {
 			out := &MessageImageUrl{
 				Url:      common.Interface2String(itemMap["url"]),
 				Detail:   common.Interface2String(itemMap["detail"]),
+				FileId:   common.Interface2String(itemMap["file_id"]),
 				MimeType: common.Interface2String(itemMap["mime_type"]),
 			}
 			return out
@@ -338,9 +434,13 @@ func (m *MediaContent) GetFile() *MessageFile {
 		}
 		if itemMap, ok := m.File.(map[string]any); ok {
 			out := &MessageFile{
-				FileName: common.Interface2String(itemMap["file_name"]),
+				FileName: common.Interface2String(itemMap["filename"]),
 				FileData: common.Interface2String(itemMap["file_data"]),
 				FileId:   common.Interface2String(itemMap["file_id"]),
+				FileUrl:  common.Interface2String(itemMap["file_url"]),
+			}
+			if out.FileName == "" {
+				out.FileName = common.Interface2String(itemMap["file_name"])
 			}
 			return out
 		}
@@ -398,8 +498,10 @@ func (m *MediaContent) ToFileSource() types.FileSource {
 }
 
 type MessageImageUrl struct {
-	Url      string `json:"url"`
-	Detail   string `json:"detail,omitempty"`
+	Url    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+	// FileId references an uploaded file instead of a URL (Responses input_image).
+	FileId   string `json:"file_id,omitempty"`
 	MimeType string
 }
 
@@ -416,6 +518,7 @@ type MessageFile struct {
 	FileName string `json:"filename,omitempty"`
 	FileData string `json:"file_data,omitempty"`
 	FileId   string `json:"file_id,omitempty"`
+	FileUrl  string `json:"file_url,omitempty"`
 }
 
 type MessageVideoUrl struct {
@@ -574,6 +677,9 @@ func (m *Message) ParseContent() []MediaContent {
 				if ok1 {
 					temp.Url = url
 				}
+				if fileId, ok := v["file_id"].(string); ok {
+					temp.FileId = fileId
+				}
 			}
 			contentList = append(contentList, MediaContent{
 				Type:     ContentTypeImageURL,
@@ -616,6 +722,14 @@ func (m *Message) ParseContent() []MediaContent {
 								FileData: fileDataStr,
 							},
 						})
+					} else if fileUrl, ok := fileData["file_url"].(string); ok && fileUrl != "" {
+						contentList = append(contentList, MediaContent{
+							Type: ContentTypeFile,
+							File: &MessageFile{
+								FileName: fileName,
+								FileUrl:  fileUrl,
+							},
+						})
 					}
 				}
 			}
@@ -747,6 +861,9 @@ func (m *Message) ParseContent() []MediaContent {
 					if ok1 {
 						temp.Url = url
 					}
+					if fileId, ok := v["file_id"].(string); ok {
+						temp.FileId = fileId
+					}
 				}
 				contentList = append(contentList, MediaContent{
 					Type:     ContentTypeImageURL,
@@ -821,8 +938,8 @@ type OpenAIResponsesRequest struct {
 	Model   string          `json:"model"`
 	Input   json.RawMessage `json:"input,omitempty"`
 	Include json.RawMessage `json:"include,omitempty"`
-	// 在后台运行推理，暂时还不支持依赖的接口
-	// Background         json.RawMessage `json:"background,omitempty"`
+	// 在后台运行推理，暂时还不支持依赖的接口，仅用于转换后的流式响应，不透传上游
+	Background         json.RawMessage `json:"background,omitempty"`
 	Conversation       json.RawMessage `json:"conversation,omitempty"`
 	ContextManagement  json.RawMessage `json:"context_management,omitempty"`
 	Instructions       json.RawMessage `json:"instructions,omitempty"`
@@ -940,6 +1057,33 @@ func (r *OpenAIResponsesRequest) GetToolsMap() []map[string]any {
 type Reasoning struct {
 	Effort  string `json:"effort,omitempty"`
 	Summary string `json:"summary,omitempty"`
+	// EffortBudget is set instead of Effort when a client sends effort as a
+	// number, which is read as a reasoning token budget.
+	EffortBudget *int `json:"-"`
+}
+
+// UnmarshalJSON accepts effort either as a level string or as a number.
+func (r *Reasoning) UnmarshalJSON(data []byte) error {
+	type Alias Reasoning
+	var aux struct {
+		Alias
+		Effort json.RawMessage `json:"effort,omitempty"`
+	}
+	if err := common.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*r = Reasoning(aux.Alias)
+	switch common.GetJsonType(aux.Effort) {
+	case "string":
+		return common.Unmarshal(aux.Effort, &r.Effort)
+	case "number":
+		var budget float64
+		if err := common.Unmarshal(aux.Effort, &budget); err != nil {
+			return err
+		}
+		r.EffortBudget = lo.ToPtr(int(budget))
+	}
+	return nil
 }
 
 type Input struct {
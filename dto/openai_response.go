@@ -10,6 +10,10 @@ import (
 
 const (
 	ResponsesOutputTypeImageGenerationCall = "image_generation_call"
+	ResponsesOutputTypeCodeInterpreterCall = "code_interpreter_call"
+	ResponsesOutputTypeMcpCall             = "mcp_call"
+	ResponsesOutputTypeMcpApprovalRequest  = "mcp_approval_request"
+	ResponsesOutputTypeFileSearchCall      = "file_search_call"
 )
 
 type SimpleResponse struct {
@@ -35,6 +39,11 @@ type OpenAITextResponseChoice struct {
 	Index        int `json:"index"`
 	Message      `json:"message"`
 	FinishReason string `json:"finish_reason"`
+	// Delta is only set when an upstream wrongly answers a non-stream
+	// request with stream-shaped choices.
+	Delta *Message `json:"delta,omitempty"`
+	// Logprobs holds the content and refusal token logprobs when requested.
+	Logprobs json.RawMessage `json:"logprobs,omitempty"`
 }
 
 type OpenAITextResponse struct {
@@ -44,7 +53,10 @@ type OpenAITextResponse struct {
 	Created any                        `json:"created"`
 	Choices []OpenAITextResponseChoice `json:"choices"`
 	Error   any                        `json:"error,omitempty"`
-	Usage   `json:"usage"`
+	// ServiceTier is the tier that actually processed the request.
+	ServiceTier       string `json:"service_tier,omitempty"`
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	Usage             `json:"usage"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -91,6 +103,56 @@ type ChatCompletionsStreamResponseChoiceDelta struct {
 	Reasoning        *string            `json:"reasoning,omitempty"`
 	Role             string             `json:"role,omitempty"`
 	ToolCalls        []ToolCallResponse `json:"tool_calls,omitempty"`
+	// FunctionCall is the deprecated single-function streaming shape.
+	FunctionCall *FunctionResponse `json:"function_call,omitempty"`
+	Refusal      *string           `json:"refusal,omitempty"`
+	// Annotations are sent incrementally by search-augmented models.
+	Annotations []MessageAnnotation `json:"annotations,omitempty"`
+	// Thinking is the reasoning field of backends that stream it as
+	// "thinking". It is kept raw since not every backend sends a string.
+	Thinking json.RawMessage `json:"thinking,omitempty"`
+	// ContentParts holds a content delta streamed as an array of parts, e.g.
+	// text plus a partial image, in place of the usual string Content.
+	ContentParts []MediaContent `json:"-"`
+}
+
+// UnmarshalJSON accepts content as either a string or an array of parts.
+func (c *ChatCompletionsStreamResponseChoiceDelta) UnmarshalJSON(data []byte) error {
+	type Alias ChatCompletionsStreamResponseChoiceDelta
+	var aux struct {
+		Alias
+		Content json.RawMessage `json:"content,omitempty"`
+	}
+	if err := common.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*c = ChatCompletionsStreamResponseChoiceDelta(aux.Alias)
+	switch common.GetJsonType(aux.Content) {
+	case "string":
+		var content string
+		if err := common.Unmarshal(aux.Content, &content); err != nil {
+			return err
+		}
+		c.Content = &content
+	case "array":
+		if err := common.Unmarshal(aux.Content, &c.ContentParts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes ContentParts back as the content array when there is
+// no string Content, so array deltas survive a decode/encode round trip.
+func (c ChatCompletionsStreamResponseChoiceDelta) MarshalJSON() ([]byte, error) {
+	type Alias ChatCompletionsStreamResponseChoiceDelta
+	if c.Content != nil || len(c.ContentParts) == 0 {
+		return common.Marshal(Alias(c))
+	}
+	return common.Marshal(struct {
+		Alias
+		Content []MediaContent `json:"content"`
+	}{Alias(c), c.ContentParts})
 }
 
 func (c *ChatCompletionsStreamResponseChoiceDelta) SetContentString(s string) {
@@ -105,13 +167,17 @@ func (c *ChatCompletionsStreamResponseChoiceDelta) GetContentString() string {
 }
 
 func (c *ChatCompletionsStreamResponseChoiceDelta) GetReasoningContent() string {
-	if c.ReasoningContent == nil && c.Reasoning == nil {
-		return ""
-	}
 	if c.ReasoningContent != nil {
 		return *c.ReasoningContent
 	}
-	return *c.Reasoning
+	if c.Reasoning != nil {
+		return *c.Reasoning
+	}
+	var thinking string
+	if common.GetJsonType(c.Thinking) == "string" && common.Unmarshal(c.Thinking, &thinking) == nil {
+		return thinking
+	}
+	return ""
 }
 
 func (c *ChatCompletionsStreamResponseChoiceDelta) SetReasoningContent(s string) {
@@ -147,6 +213,7 @@ type ChatCompletionsStreamResponse struct {
 	SystemFingerprint *string                               `json:"system_fingerprint"`
 	Choices           []ChatCompletionsStreamResponseChoice `json:"choices"`
 	Usage             *Usage                                `json:"usage"`
+	ServiceTier       string                                `json:"service_tier,omitempty"`
 }
 
 func (c *ChatCompletionsStreamResponse) IsFinished() bool {
@@ -194,6 +261,7 @@ func (c *ChatCompletionsStreamResponse) Copy() *ChatCompletionsStreamResponse {
 		SystemFingerprint: c.SystemFingerprint,
 		Choices:           choices,
 		Usage:             c.Usage,
+		ServiceTier:       c.ServiceTier,
 	}
 }
 
@@ -233,6 +301,8 @@ type Usage struct {
 	InputTokens            int                `json:"input_tokens"`
 	OutputTokens           int                `json:"output_tokens"`
 	InputTokensDetails     *InputTokenDetails `json:"input_tokens_details"`
+	// OutputTokensDetails is the Responses API name for completion_tokens_details.
+	OutputTokensDetails *OutputTokenDetails `json:"output_tokens_details,omitempty"`
 
 	// claude cache 1h
 	ClaudeCacheCreation5mTokens int `json:"claude_cache_creation_5_m_tokens"`
@@ -290,6 +360,10 @@ type OpenAIResponsesResponse struct {
 	Usage              *Usage             `json:"usage"`
 	User               json.RawMessage    `json:"user"`
 	Metadata           json.RawMessage    `json:"metadata"`
+	ServiceTier        string             `json:"service_tier,omitempty"`
+	// SystemFingerprint is carried over from Chat backends, which use it to
+	// identify their configuration.
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -297,6 +371,13 @@ func (o *OpenAIResponsesResponse) GetOpenAIError() *types.OpenAIError {
 	return GetOpenAIError(o.Error)
 }
 
+// GetStatus returns the response status, or "" when it is missing or not a string.
+func (o *OpenAIResponsesResponse) GetStatus() string {
+	var status string
+	_ = common.Unmarshal(o.Status, &status)
+	return status
+}
+
 func (o *OpenAIResponsesResponse) HasImageGenerationCall() bool {
 	if len(o.Output) == 0 {
 		return false
@@ -334,7 +415,10 @@ func (o *OpenAIResponsesResponse) GetSize() string {
 }
 
 type IncompleteDetails struct {
-	Reasoning string `json:"reasoning"`
+	Reasoning string `json:"reasoning,omitempty"`
+	// Reason is why the response is incomplete, e.g. "max_output_tokens",
+	// "content_filter" or "cancelled".
+	Reason string `json:"reason,omitempty"`
 }
 
 type ResponsesOutput struct {
@@ -348,6 +432,44 @@ type ResponsesOutput struct {
 	CallId    string                   `json:"call_id,omitempty"`
 	Name      string                   `json:"name,omitempty"`
 	Arguments json.RawMessage          `json:"arguments,omitempty"`
+	// OutputIndex is set by upstreams that number items explicitly.
+	OutputIndex *int `json:"output_index,omitempty"`
+	// CreatedAt and CompletedAt are the Unix times the item was added and
+	// finished.
+	CreatedAt   *int64 `json:"created_at,omitempty"`
+	CompletedAt *int64 `json:"completed_at,omitempty"`
+	// code_interpreter_call
+	Code        string                           `json:"code,omitempty"`
+	ContainerId string                           `json:"container_id,omitempty"`
+	Outputs     []ResponsesCodeInterpreterOutput `json:"outputs,omitempty"`
+	// custom_tool_call
+	Input string `json:"input,omitempty"`
+	// reasoning; encrypted_content is only returned when the request includes
+	// "reasoning.encrypted_content"
+	Summary          []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
+	EncryptedContent string                          `json:"encrypted_content,omitempty"`
+	// mcp_call / mcp_approval_request
+	ServerLabel string `json:"server_label,omitempty"`
+	// file_search_call; results are only returned when the request includes
+	// "file_search_call.results"
+	Queries []string                    `json:"queries,omitempty"`
+	Results []ResponsesFileSearchResult `json:"results,omitempty"`
+}
+
+type ResponsesFileSearchResult struct {
+	FileId     string         `json:"file_id"`
+	Filename   string         `json:"filename,omitempty"`
+	Score      float64        `json:"score,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// ResponsesCodeInterpreterOutput is one artifact produced by a code_interpreter_call,
+// only returned when the request includes "code_interpreter_call.outputs".
+type ResponsesCodeInterpreterOutput struct {
+	Type string `json:"type"`
+	Logs string `json:"logs,omitempty"`
+	Url  string `json:"url,omitempty"`
 }
 
 // ArgumentsString returns function call arguments in the string form expected by Chat Completions.
@@ -367,6 +489,16 @@ type ResponsesOutputContent struct {
 	Type        string        `json:"type"`
 	Text        string        `json:"text"`
 	Annotations []interface{} `json:"annotations"`
+	// output_audio parts carry base64 audio plus its transcript.
+	Data       string `json:"data,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+	Format     string `json:"format,omitempty"`
+	// Parsed is the decoded output_text of a json_schema structured output.
+	Parsed json.RawMessage `json:"parsed,omitempty"`
+	// Refusal is the text of a refusal part.
+	Refusal string `json:"refusal,omitempty"`
+	// Logprobs are the token logprobs of the part, when requested.
+	Logprobs json.RawMessage `json:"logprobs,omitempty"`
 }
 
 type ResponsesReasoningSummaryPart struct {
@@ -404,6 +536,13 @@ type ResponsesStreamResponse struct {
 	SummaryIndex *int                    `json:"summary_index,omitempty"`
 	ItemID       string                  `json:"item_id,omitempty"`
 	Part         *ResponsesOutputContent `json:"part,omitempty"`
+	// - response.output_text.annotation.added
+	AnnotationIndex *int `json:"annotation_index,omitempty"`
+	Annotation      any  `json:"annotation,omitempty"`
+	// - error
+	Code    any    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Param   string `json:"param,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -598,6 +598,27 @@ type ClaudeResponseInfo struct {
 	ResponsesStreamState *openaicompat.ChatToResponsesStreamState
 }
 
+// newResponsesStreamState starts the Responses stream conversion, echoing the
+// store, metadata and truncation of the originating Responses request,
+// parsing json_schema structured output and reporting background requests
+// as queued first.
+func newResponsesStreamState(info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo) *openaicompat.ChatToResponsesStreamState {
+	var store, metadata, text, truncation, background json.RawMessage
+	if req, ok := info.Request.(*dto.OpenAIResponsesRequest); ok && req != nil {
+		store, metadata, text, truncation, background = req.Store, req.Metadata, req.Text, req.Truncation, req.Background
+	}
+	state := openaicompat.NewChatToResponsesStreamStateWithOptions(claudeInfo.ResponseId, claudeInfo.Created, claudeInfo.Model, openaicompat.ChatToResponsesStreamOptions{
+		Store:    store,
+		Metadata: metadata,
+	})
+	state.ParseJSONOutput = openaicompat.UsesJSONSchemaTextFormat(text)
+	state.Truncation = truncation
+	if len(background) > 0 {
+		_ = common.Unmarshal(background, &state.Background)
+	}
+	return state
+}
+
 func cacheCreationTokensForOpenAIUsage(usage *dto.Usage) int {
 	if usage == nil {
 		return 0
@@ -840,7 +861,7 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 			return nil
 		}
 		if claudeInfo.ResponsesStreamState == nil {
-			claudeInfo.ResponsesStreamState = openaicompat.NewChatToResponsesStreamState(claudeInfo.ResponseId, claudeInfo.Created, claudeInfo.Model)
+			claudeInfo.ResponsesStreamState = newResponsesStreamState(info, claudeInfo)
 		}
 		for _, event := range claudeInfo.ResponsesStreamState.HandleChatChunk(response) {
 			jsonData, marshalErr := common.Marshal(event)
@@ -891,7 +912,7 @@ func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, clau
 		helper.Done(c)
 	} else if info.RelayFormat == types.RelayFormatOpenAIResponses {
 		if claudeInfo.ResponsesStreamState == nil {
-			claudeInfo.ResponsesStreamState = openaicompat.NewChatToResponsesStreamState(claudeInfo.ResponseId, claudeInfo.Created, claudeInfo.Model)
+			claudeInfo.ResponsesStreamState = newResponsesStreamState(info, claudeInfo)
 		}
 		for _, event := range claudeInfo.ResponsesStreamState.FinalEvents(claudeInfo.Usage) {
 			jsonData, err := common.Marshal(event)
@@ -969,6 +990,7 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		if convErr != nil {
 			return types.NewError(convErr, types.ErrorCodeBadResponseBody)
 		}
+		service.EchoResponsesTruncation(info.Request, responsesResp)
 		responseData, err = json.Marshal(responsesResp)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeBadResponseBody)
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/stretchr/testify/require"
 )
 
@@ -380,3 +381,25 @@ func TestRequestOpenAI2ClaudeMessage_ConvertsTextFileContentToText(t *testing.T)
 	require.NotNil(t, content[0].Text)
 	require.Equal(t, "alpha\nbeta", *content[0].Text)
 }
+
+func TestNewResponsesStreamStateBackground(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		Request: &dto.OpenAIResponsesRequest{Model: "claude-sonnet-4", Background: []byte(`true`)},
+	}
+	claudeInfo := &ClaudeResponseInfo{ResponseId: "chatcmpl-1", Created: 1700000000, Model: "claude-sonnet-4"}
+
+	state := newResponsesStreamState(info, claudeInfo)
+	require.True(t, state.Background)
+
+	content := "hi"
+	events := state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	})
+	require.Equal(t, "response.created", events[0].Type)
+	require.JSONEq(t, `"queued"`, string(events[0].Response.Status))
+	require.Equal(t, "response.queued", events[1].Type)
+	require.Equal(t, "response.in_progress", events[2].Type)
+
+	info.Request = &dto.OpenAIResponsesRequest{Model: "claude-sonnet-4"}
+	require.False(t, newResponsesStreamState(info, claudeInfo).Background)
+}
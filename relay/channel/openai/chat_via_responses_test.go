@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// runResponsesToChatStream feeds Responses stream events through
+// OaiResponsesToChatStreamHandler and returns the Chat chunks it wrote.
+func runResponsesToChatStream(t *testing.T, events ...string) ([]dto.ChatCompletionsStreamResponse, *dto.Usage, *types.NewAPIError) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	oldStreamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 300
+	t.Cleanup(func() {
+		constant.StreamingTimeout = oldStreamingTimeout
+	})
+
+	info := &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatOpenAI,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName: "gpt-4.1",
+		},
+	}
+	var body bytes.Buffer
+	for _, event := range events {
+		body.WriteString("data: " + event + "\n")
+	}
+	body.WriteString("data: [DONE]\n")
+	resp := &http.Response{Body: io.NopCloser(&body)}
+
+	usage, apiErr := OaiResponsesToChatStreamHandler(c, info, resp)
+
+	var chunks []dto.ChatCompletionsStreamResponse
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(data, &chunk))
+		chunks = append(chunks, chunk)
+	}
+	return chunks, usage, apiErr
+}
+
+func TestOaiResponsesToChatStreamHandler(t *testing.T) {
+	chunks, usage, apiErr := runResponsesToChatStream(t,
+		`{"type":"response.created","response":{"id":"resp_1","model":"gpt-4.1-2025","created_at":1700000000}}`,
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","output_index":0,"summary_index":0,"delta":"First."}`,
+		`{"type":"response.reasoning_summary_text.done","item_id":"rs_1","output_index":0,"summary_index":0,"text":"First."}`,
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","output_index":0,"summary_index":1,"delta":"Second."}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","output_index":1,"content_index":0,"delta":"Hello"}`,
+		`{"type":"response.completed","response":{"id":"resp_1","model":"gpt-4.1-2025","status":"completed","usage":{"input_tokens":9,"output_tokens":4,"total_tokens":13}}}`,
+	)
+	require.Nil(t, apiErr)
+	require.Equal(t, 9, usage.PromptTokens)
+	require.Equal(t, 4, usage.CompletionTokens)
+
+	require.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	var reasoning, content string
+	for _, chunk := range chunks {
+		require.Equal(t, "gpt-4.1-2025", chunk.Model)
+		require.Equal(t, int64(1700000000), chunk.Created)
+		reasoning += chunk.Choices[0].Delta.GetReasoningContent()
+		content += chunk.Choices[0].Delta.GetContentString()
+	}
+	require.Equal(t, "First.\n\nSecond.", reasoning)
+	require.Equal(t, "Hello", content)
+	last := chunks[len(chunks)-1]
+	require.Equal(t, "stop", *last.Choices[0].FinishReason)
+}
+
+func TestOaiResponsesToChatStreamHandlerToolCallWithoutCompleted(t *testing.T) {
+	chunks, usage, apiErr := runResponsesToChatStream(t,
+		`{"type":"response.output_item.added","output_index":0,"item":{"id":"fc_1","type":"function_call","status":"in_progress","call_id":"call_1","name":"get_weather","arguments":""}}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","output_index":0,"delta":"{\"city\":"}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","output_index":0,"delta":"\"Paris\"}"}`,
+	)
+	require.Nil(t, apiErr)
+	// No usage was reported, so it is estimated from the streamed call.
+	require.NotZero(t, usage.CompletionTokens)
+
+	var args string
+	for _, chunk := range chunks {
+		for _, tool := range chunk.Choices[0].Delta.ToolCalls {
+			require.Equal(t, "call_1", tool.ID)
+			args += tool.Function.Arguments
+		}
+	}
+	require.Equal(t, `{"city":"Paris"}`, args)
+	require.Equal(t, "tool_calls", *chunks[len(chunks)-1].Choices[0].FinishReason)
+}
+
+func TestOaiResponsesToChatStreamHandlerFailed(t *testing.T) {
+	_, _, apiErr := runResponsesToChatStream(t,
+		`{"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"Hel"}`,
+		`{"type":"response.failed","response":{"id":"resp_1","status":"failed","error":{"code":"server_error","message":"The model crashed."}}}`,
+	)
+	require.NotNil(t, apiErr)
+	require.Equal(t, "The model crashed.", apiErr.Error())
+}
+
+func TestOaiResponsesToChatStreamHandlerRefusal(t *testing.T) {
+	chunks, usage, apiErr := runResponsesToChatStream(t,
+		`{"type":"response.content_part.added","item_id":"msg_1","output_index":0,"content_index":0,"part":{"type":"refusal","refusal":"I can't"}}`,
+		`{"type":"response.refusal.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":" help with that."}`,
+	)
+	require.Nil(t, apiErr)
+	// The refusal is generated text, so it counts toward estimated usage.
+	require.NotZero(t, usage.CompletionTokens)
+
+	var refusal string
+	for _, chunk := range chunks {
+		if r := chunk.Choices[0].Delta.Refusal; r != nil {
+			refusal += *r
+		}
+	}
+	require.Equal(t, "I can't help with that.", refusal)
+	require.Equal(t, "stop", *chunks[len(chunks)-1].Choices[0].FinishReason)
+}
+
+func TestOaiResponsesToChatStreamHandlerFlushesReasoningItems(t *testing.T) {
+	chunks, _, apiErr := runResponsesToChatStream(t,
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","output_index":0,"summary_index":0,"delta":"Thin"}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"id":"rs_1","type":"reasoning","summary":[{"type":"summary_text","text":"Thinking hard."}]}}`,
+		`{"type":"response.output_item.done","output_index":1,"item":{"id":"rs_2","type":"reasoning","summary":[{"type":"summary_text","text":"More."}]}}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","output_index":2,"content_index":0,"delta":"Done"}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":3,"output_tokens":5,"total_tokens":8}}}`,
+	)
+	require.Nil(t, apiErr)
+
+	var reasoning string
+	for _, chunk := range chunks {
+		reasoning += chunk.Choices[0].Delta.GetReasoningContent()
+	}
+	require.Equal(t, "Thinking hard.\n\nMore.", reasoning)
+}
@@ -121,6 +121,7 @@ func oaiChatToResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 	if err != nil {
 		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
+	service.EchoResponsesTruncation(info.Request, responsesResp)
 
 	usage := &dto.Usage{
 		PromptTokens:     chatResp.Usage.PromptTokens,
@@ -237,6 +238,7 @@ func oaiChatStreamToResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo
 	if err != nil {
 		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
+	service.EchoResponsesTruncation(info.Request, responsesResp)
 
 	responseBody, err := common.Marshal(responsesResp)
 	if err != nil {
@@ -1,26 +1,87 @@
 package service
 
 import (
+	"encoding/json"
+
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/service/openaicompat"
 )
 
+var ErrChatResponseNoChoices = openaicompat.ErrChatResponseNoChoices
+
 func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*dto.OpenAIResponsesRequest, error) {
 	return openaicompat.ChatCompletionsRequestToResponsesRequest(req)
 }
 
+func ChatCompletionsRequestToResponsesRequestWithOptions(req *dto.GeneralOpenAIRequest, opts openaicompat.ChatToResponsesOptions) (*dto.OpenAIResponsesRequest, error) {
+	return openaicompat.ChatCompletionsRequestToResponsesRequestWithOptions(req, opts)
+}
+
 func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesResponse, id string) (*dto.OpenAITextResponse, *dto.Usage, error) {
 	return openaicompat.ResponsesResponseToChatCompletionsResponse(resp, id)
 }
 
+func ResponsesResponseToChatCompletionsResponseWithOptions(resp *dto.OpenAIResponsesResponse, id string, opts openaicompat.ResponsesToChatOptions) (*dto.OpenAITextResponse, *dto.Usage, error) {
+	return openaicompat.ResponsesResponseToChatCompletionsResponseWithOptions(resp, id, opts)
+}
+
 func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 	return openaicompat.ExtractOutputTextFromResponses(resp)
 }
 
+func ExtractOutputTextFromResponsesWithOptions(resp *dto.OpenAIResponsesResponse, opts openaicompat.ExtractOutputTextOptions) string {
+	return openaicompat.ExtractOutputTextFromResponsesWithOptions(resp, opts)
+}
+
 func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
 	return openaicompat.ResponsesRequestToChatCompletionsRequest(req)
 }
 
+func ResponsesRequestToChatCompletionsRequestWithOptions(req *dto.OpenAIResponsesRequest, opts openaicompat.ResponsesToChatOptions) (*dto.GeneralOpenAIRequest, error) {
+	return openaicompat.ResponsesRequestToChatCompletionsRequestWithOptions(req, opts)
+}
+
 func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, model string) (*dto.OpenAIResponsesResponse, error) {
 	return openaicompat.ChatCompletionsResponseToResponsesResponse(resp, model)
 }
+
+func ChatCompletionsResponseToResponsesResponseWithOptions(resp *dto.OpenAITextResponse, model string, opts openaicompat.ChatResponseToResponsesOptions) (*dto.OpenAIResponsesResponse, error) {
+	return openaicompat.ChatCompletionsResponseToResponsesResponseWithOptions(resp, model, opts)
+}
+
+func ResponsesResponseToStreamEvents(resp *dto.OpenAIResponsesResponse) []dto.ResponsesStreamResponse {
+	return openaicompat.ResponsesResponseToStreamEvents(resp)
+}
+
+func ResponsesTruncation(requested json.RawMessage) json.RawMessage {
+	return openaicompat.ResponsesTruncation(requested)
+}
+
+// EchoResponsesTruncation sets the truncation of a Responses envelope
+// converted from Chat to the one of the originating Responses request.
+func EchoResponsesTruncation(request dto.Request, resp *dto.OpenAIResponsesResponse) {
+	if resp == nil {
+		return
+	}
+	var requested json.RawMessage
+	if req, ok := request.(*dto.OpenAIResponsesRequest); ok && req != nil {
+		requested = req.Truncation
+	}
+	resp.Truncation = openaicompat.ResponsesTruncation(requested)
+}
+
+func ChatRequestEndUserID(req *dto.GeneralOpenAIRequest) string {
+	return openaicompat.ChatRequestEndUserID(req)
+}
+
+func ValidateChatRequest(req *dto.GeneralOpenAIRequest) error {
+	return openaicompat.ValidateChatRequest(req)
+}
+
+func ValidateImageURL(raw string) error {
+	return openaicompat.ValidateImageURL(raw)
+}
+
+func BatchResponsesToChat(resps []*dto.OpenAIResponsesResponse) ([]*dto.OpenAITextResponse, error) {
+	return openaicompat.BatchResponsesToChat(resps)
+}
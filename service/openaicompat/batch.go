@@ -0,0 +1,68 @@
+package openaicompat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+)
+
+// responsesToChatScratch holds the buffers the Responses to Chat conversion
+// reuses from one item to the next. Nothing in it is referenced by a
+// converted response once the conversion returns.
+type responsesToChatScratch struct {
+	groups    [][]dto.ResponsesOutput
+	toolCalls []dto.ToolCallResponse
+	reasoning bytes.Buffer
+}
+
+var responsesToChatScratchPool = sync.Pool{
+	New: func() any { return &responsesToChatScratch{} },
+}
+
+// BatchResponsesToChat converts stored Responses objects into Chat
+// completions responses on a bounded pool of workers, one per CPU. Each
+// worker reuses pooled scratch buffers across its items. Results keep the
+// input order and use each response's own ID. An item that fails to convert
+// leaves a nil result; the per-item errors are joined into the returned
+// error instead of aborting the batch.
+func BatchResponsesToChat(resps []*dto.OpenAIResponsesResponse) ([]*dto.OpenAITextResponse, error) {
+	out := make([]*dto.OpenAITextResponse, len(resps))
+	errs := make([]error, len(resps))
+
+	workers := min(runtime.GOMAXPROCS(0), len(resps))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scratch := responsesToChatScratchPool.Get().(*responsesToChatScratch)
+			defer responsesToChatScratchPool.Put(scratch)
+			for i := range jobs {
+				resp := resps[i]
+				id := ""
+				if resp != nil {
+					id = resp.ID
+				}
+				chatResp, _, err := ResponsesResponseToChatCompletionsResponseWithOptions(resp, id, ResponsesToChatOptions{scratch: scratch})
+				if err != nil {
+					errs[i] = fmt.Errorf(i18n.Translate("svc.batch_response_conversion_failed"), i, err)
+					continue
+				}
+				out[i] = chatResp
+			}
+		}()
+	}
+	for i := range resps {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return out, errors.Join(errs...)
+}
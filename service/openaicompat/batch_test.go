@@ -0,0 +1,115 @@
+package openaicompat
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchResponsesToChat(t *testing.T) {
+	resps := make([]*dto.OpenAIResponsesResponse, 50)
+	for i := range resps {
+		resps[i] = &dto.OpenAIResponsesResponse{
+			ID:    fmt.Sprintf("resp_%d", i),
+			Model: "gpt-4.1",
+			Output: []dto.ResponsesOutput{
+				{
+					Type:    "message",
+					Role:    "assistant",
+					Status:  "completed",
+					Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: fmt.Sprintf("answer %d", i)}},
+				},
+			},
+		}
+	}
+	resps[7] = nil
+	resps[31] = nil
+
+	out, err := BatchResponsesToChat(resps)
+	require.Error(t, err)
+	joined, ok := err.(interface{ Unwrap() []error })
+	require.True(t, ok)
+	require.Len(t, joined.Unwrap(), 2)
+	require.Equal(t, fmt.Errorf(i18n.Translate("svc.batch_response_conversion_failed"), 7, errors.New(i18n.Translate("svc.response_is_nil"))).Error(), joined.Unwrap()[0].Error())
+	require.Len(t, out, len(resps))
+	for i, chatResp := range out {
+		if i == 7 || i == 31 {
+			require.Nil(t, chatResp)
+			continue
+		}
+		require.Equal(t, fmt.Sprintf("resp_%d", i), chatResp.Id)
+		require.Equal(t, fmt.Sprintf("answer %d", i), chatResp.Choices[0].Message.StringContent())
+	}
+
+	out, err = BatchResponsesToChat(nil)
+	require.NoError(t, err)
+	require.Empty(t, out)
+}
+
+func TestBatchResponsesToChatMatchesSingleConversions(t *testing.T) {
+	resps := benchmarkBatchResponses(200)
+	out, err := BatchResponsesToChat(resps)
+	require.NoError(t, err)
+	for i, resp := range resps {
+		single, _, err := ResponsesResponseToChatCompletionsResponse(resp, resp.ID)
+		require.NoError(t, err)
+		// Reused scratch buffers must not leak between items.
+		require.Equal(t, single, out[i])
+	}
+}
+
+func benchmarkBatchResponses(n int) []*dto.OpenAIResponsesResponse {
+	resps := make([]*dto.OpenAIResponsesResponse, n)
+	for i := range resps {
+		resps[i] = &dto.OpenAIResponsesResponse{
+			ID:     fmt.Sprintf("resp_%d", i),
+			Model:  "gpt-4.1",
+			Status: []byte(`"completed"`),
+			Output: []dto.ResponsesOutput{
+				{Type: "reasoning", ID: "rs_1", Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: fmt.Sprintf("Looked up %d.", i)}, {Type: "summary_text", Text: " Then checked."}}},
+				{
+					Type:   "message",
+					Role:   "assistant",
+					Status: "completed",
+					Content: []dto.ResponsesOutputContent{
+						{Type: "output_text", Text: "The answer "},
+						{Type: "output_text", Text: fmt.Sprintf("is %d.", i)},
+					},
+				},
+				{Type: "function_call", ID: "fc_1", CallId: fmt.Sprintf("call_%d", i), Name: "lookup", Arguments: []byte(`"{\"q\":\"x\"}"`), Status: "completed"},
+			},
+			Usage: &dto.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		}
+	}
+	return resps
+}
+
+func BenchmarkBatchResponsesToChat(b *testing.B) {
+	resps := benchmarkBatchResponses(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BatchResponsesToChat(resps); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkResponsesToChatLoop is the per-item loop BatchResponsesToChat
+// replaces, for comparing allocations.
+func BenchmarkResponsesToChatLoop(b *testing.B) {
+	resps := benchmarkBatchResponses(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, resp := range resps {
+			if _, _, err := ResponsesResponseToChatCompletionsResponse(resp, resp.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
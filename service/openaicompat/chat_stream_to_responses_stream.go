@@ -14,11 +14,43 @@ import (
 // feeds it to HandleChatChunk; the state machine emits the correct Responses
 // API events in return.
 type ChatToResponsesStreamState struct {
-	ResponseID     string
-	CreatedAt      int64
-	Model          string
+	ResponseID  string
+	CreatedAt   int64
+	Model       string
+	ServiceTier string
+	// SystemFingerprint is the backend configuration fingerprint reported
+	// by the Chat stream.
+	SystemFingerprint string
+	Store             bool
+	Metadata          json.RawMessage
+	// Truncation is the originating request's truncation strategy; the
+	// responses report "disabled" when it is unset.
+	Truncation json.RawMessage
+	// Background is set when the originating request asked for background
+	// mode: the response is created as queued and a response.queued event
+	// precedes response.in_progress.
+	Background bool
+	// IncludeFinishReason attaches the upstream Chat finish_reason to the
+	// completed response's metadata under "finish_reason". It is a library
+	// option for callers that want the raw reason for observability; the
+	// relay handlers leave it off so clients get their metadata unchanged.
+	IncludeFinishReason bool
+	FinishReason        string
+	// ParseJSONOutput is set when the originating request used a json_schema
+	// text format: the finished message's output_text part then also carries
+	// the streamed JSON decoded under "parsed".
+	ParseJSONOutput bool
+	// TruncatedToolArguments decides what happens to tool call arguments
+	// that are missing or not valid JSON when the stream closes, typically
+	// because the upstream was cut off mid-call. By default such a call is
+	// marked incomplete so agents do not run it; repair is opt-in.
+	TruncatedToolArguments TruncatedToolArgumentsPolicy
+
 	SentCreated    bool
+	SentQueued     bool
 	SentInProgress bool
+	// Finished is set once FinalEvents or CancelEvents closed the stream.
+	Finished bool
 
 	MessageItemID       string
 	MessageOutputIndex  int
@@ -26,21 +58,100 @@ type ChatToResponsesStreamState struct {
 	MessageItemAdded    bool
 	MessageContentAdded bool
 
+	// The reasoning item gets its own output index on the first reasoning
+	// delta, so it never shares one with the message item.
+	ReasoningItemID    string
+	ReasoningItemAdded bool
+	ReasoningText      strings.Builder
+
 	NextOutputIndex int
 
-	OutputText       strings.Builder
+	OutputText strings.Builder
+	// Annotations are the output_text annotations streamed so far, in the
+	// Responses shape.
+	Annotations      []interface{}
 	ToolCallArgs     map[string]string
 	ToolCallName     map[string]string
 	ToolCallSent     map[string]bool
 	ToolCallOrder    []string
 	ToolCallOutIndex map[string]int
+	// ToolCallIndexID maps a Chat tool call index to its item ID.
+	ToolCallIndexID map[int]string
+	// ToolCallSynthetic marks item IDs generated for calls that streamed
+	// before the upstream sent their ID.
+	ToolCallSynthetic map[string]bool
+	// ToolCallUpstreamID maps a synthetic item ID to the upstream call ID
+	// that arrived after its item was added. Final items report it as
+	// call_id so clients reply with an ID the upstream issued.
+	ToolCallUpstreamID map[string]string
+	// LegacyFunctionCallID is the synthetic call ID given to a deprecated
+	// delta.function_call stream, which carries no ID of its own.
+	LegacyFunctionCallID string
+
+	// IDPrefixes are the prefixes of the item IDs the state generates.
+	IDPrefixes IDPrefixes
+
+	// ItemCreatedAt and ItemCompletedAt record, per item ID, the Unix time
+	// the item was added and finished. They are reported on the items as
+	// created_at and completed_at.
+	ItemCreatedAt   map[string]int64
+	ItemCompletedAt map[string]int64
+
+	// lastUsage is the most recent usage carried by the stream itself, on
+	// a usage-only chunk, an intermediate chunk or the final chunk.
+	lastUsage *dto.Usage
+	// now is the clock of the item timestamps.
+	now func() int64
 }
 
+// TruncatedToolArgumentsPolicy is the handling of streamed tool call
+// arguments that are missing or not valid JSON at the end of the stream.
+// Calls that streamed no arguments in a stream that finished normally are
+// parameterless and reported complete with "{}" under every policy.
+type TruncatedToolArgumentsPolicy string
+
+const (
+	// TruncatedToolArgumentsMarkIncomplete keeps the arguments as received
+	// and marks the call incomplete.
+	TruncatedToolArgumentsMarkIncomplete TruncatedToolArgumentsPolicy = ""
+	// TruncatedToolArgumentsRepair closes unterminated strings, objects and
+	// arrays on a best-effort basis. Calls that cannot be repaired are
+	// marked incomplete.
+	TruncatedToolArgumentsRepair TruncatedToolArgumentsPolicy = "repair"
+	// TruncatedToolArgumentsKeep reports the arguments as received on a
+	// completed call.
+	TruncatedToolArgumentsKeep TruncatedToolArgumentsPolicy = "keep"
+)
+
 func NewChatToResponsesStreamState(responseID string, createdAt int64, model string) *ChatToResponsesStreamState {
+	return NewChatToResponsesStreamStateWithOptions(responseID, createdAt, model, ChatToResponsesStreamOptions{})
+}
+
+// ChatToResponsesStreamOptions configures the Chat to Responses stream
+// conversion.
+type ChatToResponsesStreamOptions struct {
+	// Store and Metadata are the raw values from the originating Responses
+	// request and are echoed on the emitted responses; store defaults to
+	// true like the Responses API.
+	Store    json.RawMessage
+	Metadata json.RawMessage
+	// IDPrefixes overrides the prefixes of the generated IDs.
+	IDPrefixes IDPrefixes
+}
+
+func NewChatToResponsesStreamStateWithOptions(responseID string, createdAt int64, model string, opts ChatToResponsesStreamOptions) *ChatToResponsesStreamState {
+	prefixes := opts.IDPrefixes.withDefaults()
+	storeFlag := true
+	if len(opts.Store) > 0 {
+		_ = common.Unmarshal(opts.Store, &storeFlag)
+	}
 	return &ChatToResponsesStreamState{
-		ResponseID:          normalizeResponsesID(responseID),
+		ResponseID:          normalizeResponsesID(responseID, prefixes.Response),
+		IDPrefixes:          prefixes,
 		CreatedAt:           createdAt,
 		Model:               model,
+		Store:               storeFlag,
+		Metadata:            opts.Metadata,
 		MessageOutputIndex:  -1,
 		MessageContentIndex: 0,
 		NextOutputIndex:     0,
@@ -48,13 +159,28 @@ func NewChatToResponsesStreamState(responseID string, createdAt int64, model str
 		ToolCallName:        make(map[string]string),
 		ToolCallSent:        make(map[string]bool),
 		ToolCallOutIndex:    make(map[string]int),
+		ToolCallIndexID:     make(map[int]string),
+		ToolCallSynthetic:   make(map[string]bool),
+		ToolCallUpstreamID:  make(map[string]string),
+		ItemCreatedAt:       make(map[string]int64),
+		ItemCompletedAt:     make(map[string]int64),
+		now:                 common.GetTimestamp,
 	}
 }
 
 // HandleChatChunk converts one chat completions stream chunk into zero or more
 // Responses API events.
 func (s *ChatToResponsesStreamState) HandleChatChunk(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
-	if chunk == nil || len(chunk.Choices) == 0 {
+	if chunk == nil {
+		return nil
+	}
+	if chunk.Usage != nil {
+		s.HandleUsageChunk(chunk)
+	}
+	if chunk.ServiceTier != "" {
+		s.ServiceTier = chunk.ServiceTier
+	}
+	if len(chunk.Choices) == 0 {
 		return nil
 	}
 
@@ -64,51 +190,63 @@ func (s *ChatToResponsesStreamState) HandleChatChunk(chunk *dto.ChatCompletionsS
 	if s.CreatedAt == 0 && chunk.Created != 0 {
 		s.CreatedAt = chunk.Created
 	}
+	if fingerprint := chunk.GetSystemFingerprint(); fingerprint != "" {
+		s.SystemFingerprint = fingerprint
+	}
 
 	events := s.baseEvents()
 
+	if finishReason := chunk.Choices[0].FinishReason; finishReason != nil && *finishReason != "" {
+		s.FinishReason = *finishReason
+	}
+
 	delta := chunk.Choices[0].Delta
 
 	// Text content
 	if delta.Content != nil {
-		content := *delta.Content
-		if content != "" {
-			events = append(events, s.ensureMessageItemEvents()...)
-			events = append(events, s.ensureContentPartEvents()...)
-			s.OutputText.WriteString(content)
-			events = append(events, s.outputTextDeltaEvent(content))
+		events = append(events, s.outputTextEvents(*delta.Content)...)
+	}
+	// Some backends stream structured content; its parts go into the same
+	// output_text part, with images written as markdown links.
+	for _, part := range delta.ContentParts {
+		events = append(events, s.outputTextEvents(chatContentPartText(part))...)
+	}
+
+	// Annotations, e.g. url_citation from search-augmented models
+	if annotations := chatAnnotationsToResponses(delta.Annotations); len(annotations) > 0 {
+		events = append(events, s.ensureMessageItemEvents()...)
+		events = append(events, s.ensureContentPartEvents()...)
+		for _, annotation := range annotations {
+			events = append(events, s.annotationAddedEvent(annotation))
 		}
 	}
 
 	// Reasoning content (for models that emit reasoning_content)
 	reasoningContent := delta.GetReasoningContent()
 	if reasoningContent != "" {
-		outIndex := 0
+		events = append(events, s.ensureReasoningItemEvents()...)
+		s.ReasoningText.WriteString(reasoningContent)
 		summaryIndex := 0
 		events = append(events, dto.ResponsesStreamResponse{
 			Type:         "response.reasoning_summary_text.delta",
 			ResponseID:   s.ResponseID,
-			ItemID:       "rs_" + strings.TrimPrefix(s.ResponseID, "resp_"),
-			OutputIndex:  &outIndex,
+			ItemID:       s.ReasoningItemID,
+			OutputIndex:  s.outputIndexPtr(s.ReasoningItemID),
 			SummaryIndex: &summaryIndex,
 			Delta:        reasoningContent,
 		})
 	}
 
 	// Tool calls
-	if len(delta.ToolCalls) > 0 {
-		for _, call := range delta.ToolCalls {
-			callID := strings.TrimSpace(call.ID)
-			if callID == "" {
-				// For subsequent argument deltas, use the last known call ID
-				if call.Index != nil && *call.Index < len(s.ToolCallOrder) {
-					callID = s.ToolCallOrder[*call.Index]
-				} else if len(s.ToolCallOrder) > 0 {
-					callID = s.ToolCallOrder[len(s.ToolCallOrder)-1]
-				} else {
-					callID = "call_" + common.GetUUID()
-				}
-			}
+	toolCalls := delta.ToolCalls
+	if len(toolCalls) == 0 && delta.FunctionCall != nil {
+		toolCalls = []dto.ToolCallResponse{s.legacyFunctionCall(delta.FunctionCall)}
+	}
+	if len(toolCalls) > 0 {
+		for _, call := range toolCalls {
+			callID := s.toolCallID(call)
+			// The name may arrive after the item was added; the done event
+			// and the final output carry it.
 			if call.Function.Name != "" {
 				s.ToolCallName[callID] = call.Function.Name
 			}
@@ -138,44 +276,139 @@ func (s *ChatToResponsesStreamState) HandleChatChunk(chunk *dto.ChatCompletionsS
 	return events
 }
 
-// HandleUsageChunk processes a usage-only chunk (no choices).
+// toolCallID returns the Responses item ID for a streamed tool call delta.
+// Deltas without an ID belong to the call at the same index, or to the last
+// call. A call that streamed before its ID arrived keeps its synthetic ID so
+// its item is not added twice; the upstream ID is recorded for its call_id.
+func (s *ChatToResponsesStreamState) toolCallID(call dto.ToolCallResponse) string {
+	callID := strings.TrimSpace(call.ID)
+	if callID != "" && s.ToolCallSent[callID] {
+		return callID
+	}
+	if call.Index != nil {
+		if prev, ok := s.ToolCallIndexID[*call.Index]; ok && (callID == "" || s.ToolCallSynthetic[prev]) {
+			if callID != "" && s.ToolCallUpstreamID[prev] == "" {
+				s.ToolCallUpstreamID[prev] = callID
+			}
+			return prev
+		}
+	}
+	if callID == "" {
+		switch {
+		case call.Index != nil && *call.Index < len(s.ToolCallOrder):
+			return s.ToolCallOrder[*call.Index]
+		case call.Index == nil && len(s.ToolCallOrder) > 0:
+			return s.ToolCallOrder[len(s.ToolCallOrder)-1]
+		}
+		callID = s.IDPrefixes.syntheticCallID()
+		s.ToolCallSynthetic[callID] = true
+	}
+	if call.Index != nil {
+		s.ToolCallIndexID[*call.Index] = callID
+	}
+	return callID
+}
+
+// legacyFunctionCall maps a deprecated delta.function_call onto a single tool
+// call so it flows through the regular tool call events.
+func (s *ChatToResponsesStreamState) legacyFunctionCall(fc *dto.FunctionResponse) dto.ToolCallResponse {
+	if s.LegacyFunctionCallID == "" {
+		s.LegacyFunctionCallID = s.IDPrefixes.syntheticCallID()
+	}
+	call := dto.ToolCallResponse{
+		ID:       s.LegacyFunctionCallID,
+		Type:     "function",
+		Function: *fc,
+	}
+	call.SetIndex(0)
+	return call
+}
+
+// HandleUsageChunk extracts the usage carried by a chunk, whether it is a
+// usage-only chunk (no choices) or the final completion chunk.
 func (s *ChatToResponsesStreamState) HandleUsageChunk(chunk *dto.ChatCompletionsStreamResponse) *dto.Usage {
 	if chunk == nil || chunk.Usage == nil {
 		return nil
 	}
-	usage := &dto.Usage{
-		PromptTokens:     chunk.Usage.PromptTokens,
-		CompletionTokens: chunk.Usage.CompletionTokens,
-		TotalTokens:      chunk.Usage.TotalTokens,
-		InputTokens:      chunk.Usage.PromptTokens,
-		OutputTokens:     chunk.Usage.CompletionTokens,
+	src := chunk.Usage
+	var usage *dto.Usage
+	if src.PromptTokens == 0 && src.CompletionTokens == 0 && (src.InputTokens != 0 || src.OutputTokens != 0) {
+		// Bridged streams may embed the upstream Responses usage as-is.
+		usage = responsesUsageToChatUsage(src)
+	} else {
+		usage = &dto.Usage{
+			PromptTokens:     src.PromptTokens,
+			CompletionTokens: src.CompletionTokens,
+			TotalTokens:      src.TotalTokens,
+			InputTokens:      src.PromptTokens,
+			OutputTokens:     src.CompletionTokens,
+		}
+		usage.PromptTokensDetails = src.PromptTokensDetails
+		usage.CompletionTokenDetails = src.CompletionTokenDetails
 	}
-	usage.PromptTokensDetails = chunk.Usage.PromptTokensDetails
-	usage.CompletionTokenDetails = chunk.Usage.CompletionTokenDetails
+	s.lastUsage = usage
 	return usage
 }
 
+// LastUsage returns the most recent usage reported on the stream, or nil if
+// none has been seen yet. Later reports replace earlier ones.
+func (s *ChatToResponsesStreamState) LastUsage() *dto.Usage {
+	return s.lastUsage
+}
+
 // FinalEvents emits the closing events: content done, tool calls done, and
-// response.completed.
+// response.completed. A nil usage falls back to the usage seen on the stream.
 func (s *ChatToResponsesStreamState) FinalEvents(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	if s.Finished {
+		return nil
+	}
+	s.Finished = true
+	if usage == nil {
+		usage = s.lastUsage
+	}
+	return s.closeEvents("completed", usage, nil)
+}
+
+// CancelEvents closes the stream after the client cancelled or disconnected:
+// open items are finished as incomplete and a response.incomplete event with
+// reason "cancelled" is emitted. It returns nothing once the stream has been
+// closed by FinalEvents or a previous CancelEvents.
+func (s *ChatToResponsesStreamState) CancelEvents() []dto.ResponsesStreamResponse {
+	if s.Finished {
+		return nil
+	}
+	s.Finished = true
+	return s.closeEvents("incomplete", s.lastUsage, &dto.IncompleteDetails{Reason: "cancelled"})
+}
+
+// closeEvents finishes all open items with the given status and emits the
+// terminal response event (response.completed or response.incomplete).
+func (s *ChatToResponsesStreamState) closeEvents(status string, usage *dto.Usage, incomplete *dto.IncompleteDetails) []dto.ResponsesStreamResponse {
 	events := s.baseEvents()
+	s.completeItems()
 
-	// Finalize message item
+	// Items are finalized in output index order, the order they were added.
+	doneByIndex := make(map[int][]dto.ResponsesStreamResponse)
+	if s.ReasoningItemAdded {
+		doneByIndex[s.ToolCallOutIndex[s.ReasoningItemID]] = s.reasoningDoneEvents(status)
+	}
 	if s.MessageItemAdded {
 		text := s.OutputText.String()
+		var done []dto.ResponsesStreamResponse
 		if s.MessageContentAdded {
-			events = append(events, s.outputTextDoneEvent(text))
-			events = append(events, s.contentPartDoneEvent(text))
+			done = append(done, s.outputTextDoneEvent(text), s.contentPartDoneEvent(text))
 		}
-		events = append(events, s.messageItemDoneEvent(text))
+		doneByIndex[s.MessageOutputIndex] = append(done, s.messageItemDoneEvent(text, status))
 	}
-
-	// Finalize tool calls
 	for _, callID := range s.ToolCallOrder {
 		outIndex := s.outputIndexPtr(callID)
-		args := s.ToolCallArgs[callID]
-		if args != "" {
-			events = append(events, dto.ResponsesStreamResponse{
+		if outIndex == nil {
+			continue
+		}
+		var done []dto.ResponsesStreamResponse
+		item := s.finalToolCallItem(callID, status)
+		if args := string(item.Arguments); args != "" {
+			done = append(done, dto.ResponsesStreamResponse{
 				Type:        "response.function_call_arguments.done",
 				ResponseID:  s.ResponseID,
 				ItemID:      callID,
@@ -183,37 +416,40 @@ func (s *ChatToResponsesStreamState) FinalEvents(usage *dto.Usage) []dto.Respons
 				Arguments:   args,
 			})
 		}
-		events = append(events, dto.ResponsesStreamResponse{
+		doneByIndex[*outIndex] = append(done, dto.ResponsesStreamResponse{
 			Type:        "response.output_item.done",
 			ResponseID:  s.ResponseID,
 			ItemID:      callID,
 			OutputIndex: outIndex,
-			Item: &dto.ResponsesOutput{
-				Type:      "function_call",
-				ID:        callID,
-				Status:    "completed",
-				CallId:    callID,
-				Name:      s.ToolCallName[callID],
-				Arguments: json.RawMessage(args),
-			},
+			Item:        &item,
 		})
 	}
+	for i := 0; i < s.NextOutputIndex; i++ {
+		events = append(events, doneByIndex[i]...)
+	}
 
 	// Build final output and usage
-	output := s.buildFinalOutput()
+	output := s.buildFinalOutput(status)
 	finalUsage := s.buildFinalUsage(usage)
 
+	statusRaw, _ := common.Marshal(status)
 	resp := &dto.OpenAIResponsesResponse{
-		ID:        s.ResponseID,
-		Object:    "response",
-		CreatedAt: int(s.CreatedAt),
-		Status:    json.RawMessage(`"completed"`),
-		Model:     s.Model,
-		Output:    output,
-		Usage:     finalUsage,
+		ID:                s.ResponseID,
+		Object:            "response",
+		CreatedAt:         int(s.CreatedAt),
+		Status:            statusRaw,
+		IncompleteDetails: incomplete,
+		Model:             s.Model,
+		Output:            output,
+		Usage:             finalUsage,
+		ServiceTier:       s.ServiceTier,
+		SystemFingerprint: s.SystemFingerprint,
+		Store:             s.Store,
+		Metadata:          s.finalMetadata(),
+		Truncation:        ResponsesTruncation(s.Truncation),
 	}
 	events = append(events, dto.ResponsesStreamResponse{
-		Type:       "response.completed",
+		Type:       "response." + status,
 		ResponseID: s.ResponseID,
 		Response:   resp,
 	})
@@ -221,12 +457,49 @@ func (s *ChatToResponsesStreamState) FinalEvents(usage *dto.Usage) []dto.Respons
 	return events
 }
 
-func (s *ChatToResponsesStreamState) baseEvents() []dto.ResponsesStreamResponse {
+// QueuedEvents emits response.created and response.queued for a background
+// request before any upstream chunk has arrived. response.in_progress
+// follows with the first chunk. It is a no-op outside background mode.
+func (s *ChatToResponsesStreamState) QueuedEvents() []dto.ResponsesStreamResponse {
+	if !s.Background {
+		return nil
+	}
+	return s.startEvents()
+}
+
+func (s *ChatToResponsesStreamState) startEvents() []dto.ResponsesStreamResponse {
 	events := make([]dto.ResponsesStreamResponse, 0, 2)
 	if !s.SentCreated {
 		events = append(events, s.createdEvent())
 		s.SentCreated = true
 	}
+	if s.Background && !s.SentQueued {
+		events = append(events, s.lifecycleEvent("response.queued", "queued"))
+		s.SentQueued = true
+	}
+	return events
+}
+
+// finalMetadata returns the request metadata, plus the raw finish reason when
+// IncludeFinishReason is set.
+func (s *ChatToResponsesStreamState) finalMetadata() json.RawMessage {
+	if !s.IncludeFinishReason || s.FinishReason == "" {
+		return s.Metadata
+	}
+	metadata := make(map[string]any)
+	if len(s.Metadata) > 0 {
+		_ = common.Unmarshal(s.Metadata, &metadata)
+	}
+	metadata["finish_reason"] = s.FinishReason
+	raw, err := common.Marshal(metadata)
+	if err != nil {
+		return s.Metadata
+	}
+	return raw
+}
+
+func (s *ChatToResponsesStreamState) baseEvents() []dto.ResponsesStreamResponse {
+	events := s.startEvents()
 	if !s.SentInProgress {
 		events = append(events, s.inProgressEvent())
 		s.SentInProgress = true
@@ -235,13 +508,21 @@ func (s *ChatToResponsesStreamState) baseEvents() []dto.ResponsesStreamResponse
 }
 
 func (s *ChatToResponsesStreamState) createdEvent() dto.ResponsesStreamResponse {
+	status := json.RawMessage(`"in_progress"`)
+	if s.Background {
+		status = json.RawMessage(`"queued"`)
+	}
 	resp := &dto.OpenAIResponsesResponse{
-		ID:        s.ResponseID,
-		Object:    "response",
-		CreatedAt: int(s.CreatedAt),
-		Status:    json.RawMessage(`"in_progress"`),
-		Model:     s.Model,
-		Output:    []dto.ResponsesOutput{},
+		ID:                s.ResponseID,
+		Object:            "response",
+		CreatedAt:         int(s.CreatedAt),
+		Status:            status,
+		Model:             s.Model,
+		Output:            []dto.ResponsesOutput{},
+		Store:             s.Store,
+		Metadata:          s.Metadata,
+		Truncation:        ResponsesTruncation(s.Truncation),
+		SystemFingerprint: s.SystemFingerprint,
 	}
 	return dto.ResponsesStreamResponse{
 		Type:       "response.created",
@@ -251,46 +532,130 @@ func (s *ChatToResponsesStreamState) createdEvent() dto.ResponsesStreamResponse
 }
 
 func (s *ChatToResponsesStreamState) inProgressEvent() dto.ResponsesStreamResponse {
+	return s.lifecycleEvent("response.in_progress", "in_progress")
+}
+
+func (s *ChatToResponsesStreamState) lifecycleEvent(eventType string, status string) dto.ResponsesStreamResponse {
+	statusRaw, _ := common.Marshal(status)
 	resp := &dto.OpenAIResponsesResponse{
-		ID:        s.ResponseID,
-		Object:    "response",
-		CreatedAt: int(s.CreatedAt),
-		Status:    json.RawMessage(`"in_progress"`),
-		Model:     s.Model,
-		Output:    []dto.ResponsesOutput{},
+		ID:                s.ResponseID,
+		Object:            "response",
+		CreatedAt:         int(s.CreatedAt),
+		Status:            statusRaw,
+		Model:             s.Model,
+		Output:            []dto.ResponsesOutput{},
+		SystemFingerprint: s.SystemFingerprint,
 	}
 	return dto.ResponsesStreamResponse{
-		Type:       "response.in_progress",
+		Type:       eventType,
 		ResponseID: s.ResponseID,
 		Response:   resp,
 	}
 }
 
+func (s *ChatToResponsesStreamState) ensureReasoningItemEvents() []dto.ResponsesStreamResponse {
+	if s.ReasoningItemAdded {
+		return nil
+	}
+	s.ReasoningItemAdded = true
+	if s.ReasoningItemID == "" {
+		s.ReasoningItemID = s.IDPrefixes.Reasoning + strings.TrimPrefix(s.ResponseID, s.IDPrefixes.Response)
+	}
+	outIndex := s.allocOutputIndex(s.ReasoningItemID)
+	summaryIndex := 0
+	return []dto.ResponsesStreamResponse{
+		{
+			Type:        "response.output_item.added",
+			ResponseID:  s.ResponseID,
+			ItemID:      s.ReasoningItemID,
+			OutputIndex: &outIndex,
+			Item: s.stampItem(&dto.ResponsesOutput{
+				ID:      s.ReasoningItemID,
+				Type:    "reasoning",
+				Status:  "in_progress",
+				Summary: []dto.ResponsesReasoningSummaryPart{},
+			}),
+		},
+		{
+			Type:         "response.reasoning_summary_part.added",
+			ResponseID:   s.ResponseID,
+			ItemID:       s.ReasoningItemID,
+			OutputIndex:  &outIndex,
+			SummaryIndex: &summaryIndex,
+			Part:         &dto.ResponsesOutputContent{Type: "summary_text"},
+		},
+	}
+}
+
+func (s *ChatToResponsesStreamState) reasoningDoneEvents(status string) []dto.ResponsesStreamResponse {
+	text := s.ReasoningText.String()
+	outIndex := s.outputIndexPtr(s.ReasoningItemID)
+	summaryIndex := 0
+	item := s.reasoningItem(status)
+	return []dto.ResponsesStreamResponse{
+		{
+			Type:         "response.reasoning_summary_text.done",
+			ResponseID:   s.ResponseID,
+			ItemID:       s.ReasoningItemID,
+			OutputIndex:  outIndex,
+			SummaryIndex: &summaryIndex,
+			Text:         text,
+		},
+		{
+			Type:         "response.reasoning_summary_part.done",
+			ResponseID:   s.ResponseID,
+			ItemID:       s.ReasoningItemID,
+			OutputIndex:  outIndex,
+			SummaryIndex: &summaryIndex,
+			Part:         &dto.ResponsesOutputContent{Type: "summary_text", Text: text},
+		},
+		{
+			Type:        "response.output_item.done",
+			ResponseID:  s.ResponseID,
+			ItemID:      s.ReasoningItemID,
+			OutputIndex: outIndex,
+			Item:        &item,
+		},
+	}
+}
+
+func (s *ChatToResponsesStreamState) reasoningItem(status string) dto.ResponsesOutput {
+	item := dto.ResponsesOutput{
+		ID:     s.ReasoningItemID,
+		Type:   "reasoning",
+		Status: status,
+		Summary: []dto.ResponsesReasoningSummaryPart{
+			{Type: "summary_text", Text: s.ReasoningText.String()},
+		},
+	}
+	return *s.stampItem(&item)
+}
+
 func (s *ChatToResponsesStreamState) ensureMessageItemEvents() []dto.ResponsesStreamResponse {
 	if s.MessageItemAdded {
 		return nil
 	}
 	s.MessageItemAdded = true
-	if s.MessageOutputIndex < 0 {
-		s.MessageOutputIndex = s.NextOutputIndex
-		s.NextOutputIndex++
-	}
 	if s.MessageItemID == "" {
-		s.MessageItemID = "msg_" + common.GetUUID()
+		s.MessageItemID = s.IDPrefixes.Message + common.GetUUID()
 	}
+	// The message takes the next slot after any reasoning or tool call item
+	// already added, from the same counter.
+	s.MessageOutputIndex = s.allocOutputIndex(s.MessageItemID)
 	outIndex := s.MessageOutputIndex
 	return []dto.ResponsesStreamResponse{
 		{
 			Type:        "response.output_item.added",
 			ResponseID:  s.ResponseID,
+			ItemID:      s.MessageItemID,
 			OutputIndex: &outIndex,
-			Item: &dto.ResponsesOutput{
+			Item: s.stampItem(&dto.ResponsesOutput{
 				ID:      s.MessageItemID,
 				Type:    "message",
 				Status:  "in_progress",
 				Role:    "assistant",
 				Content: []dto.ResponsesOutputContent{},
-			},
+			}),
 		},
 	}
 }
@@ -345,13 +710,35 @@ func (s *ChatToResponsesStreamState) outputTextDoneEvent(text string) dto.Respon
 	}
 }
 
+func (s *ChatToResponsesStreamState) annotationAddedEvent(annotation interface{}) dto.ResponsesStreamResponse {
+	outIndex := s.MessageOutputIndex
+	contentIndex := s.MessageContentIndex
+	annotationIndex := len(s.Annotations)
+	s.Annotations = append(s.Annotations, annotation)
+	return dto.ResponsesStreamResponse{
+		Type:            "response.output_text.annotation.added",
+		ResponseID:      s.ResponseID,
+		ItemID:          s.MessageItemID,
+		OutputIndex:     &outIndex,
+		ContentIndex:    &contentIndex,
+		AnnotationIndex: &annotationIndex,
+		Annotation:      annotation,
+	}
+}
+
+// outputAnnotations returns the streamed annotations; it never returns nil
+// so the field always encodes as a list.
+func (s *ChatToResponsesStreamState) outputAnnotations() []interface{} {
+	return append([]interface{}{}, s.Annotations...)
+}
+
 func (s *ChatToResponsesStreamState) contentPartDoneEvent(text string) dto.ResponsesStreamResponse {
 	outIndex := s.MessageOutputIndex
 	contentIndex := s.MessageContentIndex
 	part := dto.ResponsesOutputContent{
 		Type:        "output_text",
 		Text:        text,
-		Annotations: []interface{}{},
+		Annotations: s.outputAnnotations(),
 	}
 	return dto.ResponsesStreamResponse{
 		Type:         "response.content_part.done",
@@ -363,21 +750,9 @@ func (s *ChatToResponsesStreamState) contentPartDoneEvent(text string) dto.Respo
 	}
 }
 
-func (s *ChatToResponsesStreamState) messageItemDoneEvent(text string) dto.ResponsesStreamResponse {
+func (s *ChatToResponsesStreamState) messageItemDoneEvent(text string, status string) dto.ResponsesStreamResponse {
 	outIndex := s.MessageOutputIndex
-	item := dto.ResponsesOutput{
-		ID:     s.MessageItemID,
-		Type:   "message",
-		Status: "completed",
-		Role:   "assistant",
-		Content: []dto.ResponsesOutputContent{
-			{
-				Type:        "output_text",
-				Text:        text,
-				Annotations: []interface{}{},
-			},
-		},
-	}
+	item := s.messageItem(text, status)
 	return dto.ResponsesStreamResponse{
 		Type:        "response.output_item.done",
 		ResponseID:  s.ResponseID,
@@ -387,6 +762,35 @@ func (s *ChatToResponsesStreamState) messageItemDoneEvent(text string) dto.Respo
 	}
 }
 
+func (s *ChatToResponsesStreamState) messageItem(text string, status string) dto.ResponsesOutput {
+	item := dto.ResponsesOutput{
+		ID:      s.MessageItemID,
+		Type:    "message",
+		Status:  status,
+		Role:    "assistant",
+		Content: s.messageContent(text, status),
+	}
+	return *s.stampItem(&item)
+}
+
+// messageContent builds the content of the finished message item. With
+// ParseJSONOutput, a completed message whose text is a JSON object also gets
+// the decoded object; truncated or invalid JSON is left as text only.
+func (s *ChatToResponsesStreamState) messageContent(text string, status string) []dto.ResponsesOutputContent {
+	part := dto.ResponsesOutputContent{
+		Type:        "output_text",
+		Text:        text,
+		Annotations: s.outputAnnotations(),
+	}
+	if s.ParseJSONOutput && status == "completed" {
+		trimmed := strings.TrimSpace(text)
+		if common.GetJsonType(json.RawMessage(trimmed)) == "object" && json.Valid([]byte(trimmed)) {
+			part.Parsed = json.RawMessage(trimmed)
+		}
+	}
+	return []dto.ResponsesOutputContent{part}
+}
+
 func (s *ChatToResponsesStreamState) toolItemAddedEvent(callID string, outIndex int) dto.ResponsesStreamResponse {
 	item := dto.ResponsesOutput{
 		Type:   "function_call",
@@ -400,7 +804,7 @@ func (s *ChatToResponsesStreamState) toolItemAddedEvent(callID string, outIndex
 		ResponseID:  s.ResponseID,
 		ItemID:      callID,
 		OutputIndex: &outIndex,
-		Item:        &item,
+		Item:        s.stampItem(&item),
 	}
 }
 
@@ -411,9 +815,31 @@ func (s *ChatToResponsesStreamState) allocOutputIndex(callID string) int {
 	idx := s.NextOutputIndex
 	s.NextOutputIndex++
 	s.ToolCallOutIndex[callID] = idx
+	s.ItemCreatedAt[callID] = s.now()
 	return idx
 }
 
+// completeItems records the completion time of every item not finished yet.
+func (s *ChatToResponsesStreamState) completeItems() {
+	now := s.now()
+	for itemID := range s.ToolCallOutIndex {
+		if _, ok := s.ItemCompletedAt[itemID]; !ok {
+			s.ItemCompletedAt[itemID] = now
+		}
+	}
+}
+
+// stampItem sets the recorded created_at and completed_at of item.
+func (s *ChatToResponsesStreamState) stampItem(item *dto.ResponsesOutput) *dto.ResponsesOutput {
+	if createdAt, ok := s.ItemCreatedAt[item.ID]; ok {
+		item.CreatedAt = &createdAt
+	}
+	if completedAt, ok := s.ItemCompletedAt[item.ID]; ok {
+		item.CompletedAt = &completedAt
+	}
+	return item
+}
+
 func (s *ChatToResponsesStreamState) outputIndexPtr(callID string) *int {
 	idx, ok := s.ToolCallOutIndex[callID]
 	if !ok {
@@ -422,37 +848,68 @@ func (s *ChatToResponsesStreamState) outputIndexPtr(callID string) *int {
 	return &idx
 }
 
-func (s *ChatToResponsesStreamState) buildFinalOutput() []dto.ResponsesOutput {
+// finishedNormally reports whether the upstream sent a finish reason that
+// ends the turn, rather than the stream just stopping.
+func (s *ChatToResponsesStreamState) finishedNormally() bool {
+	switch s.FinishReason {
+	case "tool_calls", "function_call", "stop":
+		return true
+	}
+	return false
+}
+
+// finalToolCallItem is the finished function_call item of callID. Arguments
+// never received or cut off by the end of the stream are handled per
+// TruncatedToolArguments.
+func (s *ChatToResponsesStreamState) finalToolCallItem(callID string, status string) dto.ResponsesOutput {
+	args := s.ToolCallArgs[callID]
+	if strings.TrimSpace(args) == "" && s.finishedNormally() {
+		// Parameterless calls stream no arguments at all, e.g. Claude
+		// tool_use blocks without input; they are complete. Without a
+		// finish reason the stream may have been cut off after the name.
+		args = "{}"
+	}
+	if !json.Valid([]byte(args)) {
+		switch s.TruncatedToolArguments {
+		case TruncatedToolArgumentsRepair:
+			if repaired, ok := repairTruncatedJSON(args); ok {
+				args = repaired
+			} else {
+				status = "incomplete"
+			}
+		case TruncatedToolArgumentsMarkIncomplete:
+			status = "incomplete"
+		}
+	}
+	upstreamID := callID
+	if id := s.ToolCallUpstreamID[callID]; id != "" {
+		upstreamID = id
+	}
+	item := dto.ResponsesOutput{
+		Type:      "function_call",
+		ID:        callID,
+		Status:    status,
+		CallId:    upstreamID,
+		Name:      s.ToolCallName[callID],
+		Arguments: json.RawMessage(args),
+	}
+	return *s.stampItem(&item)
+}
+
+func (s *ChatToResponsesStreamState) buildFinalOutput(status string) []dto.ResponsesOutput {
 	itemsByIndex := make(map[int]dto.ResponsesOutput)
+	if s.ReasoningItemAdded {
+		itemsByIndex[s.ToolCallOutIndex[s.ReasoningItemID]] = s.reasoningItem(status)
+	}
 	if s.MessageItemAdded {
-		text := s.OutputText.String()
-		itemsByIndex[s.MessageOutputIndex] = dto.ResponsesOutput{
-			ID:     s.MessageItemID,
-			Type:   "message",
-			Status: "completed",
-			Role:   "assistant",
-			Content: []dto.ResponsesOutputContent{
-				{
-					Type:        "output_text",
-					Text:        text,
-					Annotations: []interface{}{},
-				},
-			},
-		}
+		itemsByIndex[s.MessageOutputIndex] = s.messageItem(s.OutputText.String(), status)
 	}
 	for _, callID := range s.ToolCallOrder {
 		idx, ok := s.ToolCallOutIndex[callID]
 		if !ok {
 			continue
 		}
-		itemsByIndex[idx] = dto.ResponsesOutput{
-			Type:      "function_call",
-			ID:        callID,
-			Status:    "completed",
-			CallId:    callID,
-			Name:      s.ToolCallName[callID],
-			Arguments: json.RawMessage(s.ToolCallArgs[callID]),
-		}
+		itemsByIndex[idx] = s.finalToolCallItem(callID, status)
 	}
 	output := make([]dto.ResponsesOutput, 0, len(itemsByIndex))
 	for i := 0; i < s.NextOutputIndex; i++ {
@@ -463,12 +920,37 @@ func (s *ChatToResponsesStreamState) buildFinalOutput() []dto.ResponsesOutput {
 	return output
 }
 
-func (s *ChatToResponsesStreamState) buildFinalUsage(usage *dto.Usage) *dto.Usage {
-	if usage == nil {
-		return &dto.Usage{}
+// outputTextEvents appends content to the message's output_text part,
+// opening the message item and the part first if needed.
+func (s *ChatToResponsesStreamState) outputTextEvents(content string) []dto.ResponsesStreamResponse {
+	if content == "" {
+		return nil
+	}
+	events := s.ensureMessageItemEvents()
+	events = append(events, s.ensureContentPartEvents()...)
+	s.OutputText.WriteString(content)
+	return append(events, s.outputTextDeltaEvent(content))
+}
+
+// chatContentPartText renders a streamed Chat content part as output text.
+// Parts with no text form, such as audio, yield an empty string.
+func chatContentPartText(part dto.MediaContent) string {
+	switch part.Type {
+	case dto.ContentTypeText:
+		return part.Text
+	case dto.ContentTypeImageURL:
+		if image := part.GetImageMedia(); image != nil && image.Url != "" {
+			return "![image](" + image.Url + ")"
+		}
 	}
+	return ""
+}
+
+func (s *ChatToResponsesStreamState) buildFinalUsage(usage *dto.Usage) *dto.Usage {
 	final := &dto.Usage{}
-	*final = *usage
+	if usage != nil {
+		*final = *usage
+	}
 	if final.InputTokens == 0 {
 		final.InputTokens = final.PromptTokens
 	}
@@ -478,22 +960,6 @@ func (s *ChatToResponsesStreamState) buildFinalUsage(usage *dto.Usage) *dto.Usag
 	if final.TotalTokens == 0 {
 		final.TotalTokens = final.PromptTokens + final.CompletionTokens
 	}
+	setResponsesUsageDetails(final)
 	return final
 }
-
-func normalizeResponsesID(id string) string {
-	id = strings.TrimSpace(id)
-	if id == "" {
-		return "resp_" + common.GetUUID()
-	}
-	if strings.HasPrefix(id, "resp_") {
-		return id
-	}
-	if strings.HasPrefix(id, "chatcmpl-") {
-		return "resp_" + strings.TrimPrefix(id, "chatcmpl-")
-	}
-	if strings.HasPrefix(id, "chatcmpl_") {
-		return "resp_" + strings.TrimPrefix(id, "chatcmpl_")
-	}
-	return "resp_" + id
-}
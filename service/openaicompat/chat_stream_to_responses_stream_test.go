@@ -0,0 +1,698 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatToResponsesStreamUsageEmbeddedInFinalChunk(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	var first dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4.1",
+		"choices":[{"index":0,"delta":{"role":"assistant","content":"hi"}}]
+	}`), &first))
+	state.HandleChatChunk(&first)
+
+	// The bridged upstream puts its Responses-shaped usage on the final
+	// chunk instead of sending a separate usage-only chunk.
+	var last dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4.1",
+		"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],
+		"usage":{"input_tokens":12,"output_tokens":3,"total_tokens":15}
+	}`), &last))
+	state.HandleChatChunk(&last)
+
+	events := state.FinalEvents(nil)
+	completed := events[len(events)-1]
+	require.Equal(t, "response.completed", completed.Type)
+	require.NotNil(t, completed.Response.Usage)
+	require.Equal(t, 12, completed.Response.Usage.InputTokens)
+	require.Equal(t, 3, completed.Response.Usage.OutputTokens)
+	require.Equal(t, 15, completed.Response.Usage.TotalTokens)
+}
+
+func TestChatToResponsesStreamEchoesStoreAndMetadata(t *testing.T) {
+	metadata := []byte(`{"trace":"abc"}`)
+	state := NewChatToResponsesStreamStateWithOptions("chatcmpl-1", 1700000000, "gpt-4.1", ChatToResponsesStreamOptions{Store: []byte(`true`), Metadata: metadata})
+
+	content := "hi"
+	events := state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	})
+	require.Equal(t, "response.created", events[0].Type)
+	require.True(t, events[0].Response.Store)
+	require.JSONEq(t, string(metadata), string(events[0].Response.Metadata))
+
+	final := state.FinalEvents(nil)
+	completed := final[len(final)-1]
+	require.Equal(t, "response.completed", completed.Type)
+	require.True(t, completed.Response.Store)
+	require.JSONEq(t, string(metadata), string(completed.Response.Metadata))
+
+	state = NewChatToResponsesStreamStateWithOptions("chatcmpl-2", 1700000000, "gpt-4.1", ChatToResponsesStreamOptions{Store: []byte(`false`)})
+	final = state.FinalEvents(nil)
+	require.False(t, final[len(final)-1].Response.Store)
+}
+
+func TestChatToResponsesStreamBackgroundQueued(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	state.Background = true
+
+	queued := state.QueuedEvents()
+	require.Len(t, queued, 2)
+	require.Equal(t, "response.created", queued[0].Type)
+	require.JSONEq(t, `"queued"`, string(queued[0].Response.Status))
+	require.Equal(t, "response.queued", queued[1].Type)
+	require.JSONEq(t, `"queued"`, string(queued[1].Response.Status))
+	require.Empty(t, state.QueuedEvents())
+
+	content := "hi"
+	events := state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	})
+	require.Equal(t, "response.in_progress", events[0].Type)
+	require.JSONEq(t, `"in_progress"`, string(events[0].Response.Status))
+}
+
+func TestChatToResponsesStreamLegacyFunctionCall(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-3.5-turbo")
+
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","function_call":{"name":"get_weather","arguments":""}}}]}`,
+		`{"choices":[{"index":0,"delta":{"function_call":{"arguments":"{\"city\":"}}}]}`,
+		`{"choices":[{"index":0,"delta":{"function_call":{"arguments":"\"Paris\"}"}}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"function_call"}]}`,
+	}
+	var events []dto.ResponsesStreamResponse
+	for _, raw := range chunks {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.Unmarshal([]byte(raw), &chunk))
+		events = append(events, state.HandleChatChunk(&chunk)...)
+	}
+	events = append(events, state.FinalEvents(nil)...)
+
+	var added, argDeltas int
+	var done *dto.ResponsesOutput
+	for _, event := range events {
+		switch event.Type {
+		case "response.output_item.added":
+			added++
+		case "response.function_call_arguments.delta":
+			argDeltas++
+		case "response.output_item.done":
+			done = event.Item
+		}
+	}
+	require.Equal(t, 1, added)
+	require.Equal(t, 2, argDeltas)
+	require.NotNil(t, done)
+	require.Equal(t, "function_call", done.Type)
+	require.Equal(t, "get_weather", done.Name)
+	require.Equal(t, `{"city":"Paris"}`, done.ArgumentsString())
+	require.Equal(t, state.LegacyFunctionCallID, done.CallId)
+}
+
+func TestChatToResponsesStreamFinishReasonMetadata(t *testing.T) {
+	state := NewChatToResponsesStreamStateWithOptions("chatcmpl-1", 1700000000, "gpt-4.1", ChatToResponsesStreamOptions{Metadata: []byte(`{"trace":"abc"}`)})
+	state.IncludeFinishReason = true
+
+	content := "partial"
+	finishReason := "length"
+	state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	})
+	state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finishReason}},
+	})
+
+	final := state.FinalEvents(nil)
+	completed := final[len(final)-1]
+	require.Equal(t, "response.completed", completed.Type)
+	require.JSONEq(t, `{"trace":"abc","finish_reason":"length"}`, string(completed.Response.Metadata))
+}
+
+func TestChatToResponsesStreamCancelEvents(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	content := "half an ans"
+	state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	})
+
+	events := state.CancelEvents()
+	require.NotEmpty(t, events)
+	var itemDone *dto.ResponsesOutput
+	for _, event := range events {
+		if event.Type == "response.output_item.done" {
+			itemDone = event.Item
+		}
+	}
+	require.NotNil(t, itemDone)
+	require.Equal(t, "incomplete", itemDone.Status)
+
+	last := events[len(events)-1]
+	require.Equal(t, "response.incomplete", last.Type)
+	require.JSONEq(t, `"incomplete"`, string(last.Response.Status))
+	require.NotNil(t, last.Response.IncompleteDetails)
+	require.Equal(t, "cancelled", last.Response.IncompleteDetails.Reason)
+	require.Equal(t, "incomplete", last.Response.Output[0].Status)
+
+	require.Empty(t, state.CancelEvents())
+	require.Empty(t, state.FinalEvents(nil))
+
+	state = NewChatToResponsesStreamState("chatcmpl-2", 1700000000, "gpt-4.1")
+	require.NotEmpty(t, state.FinalEvents(nil))
+	require.Empty(t, state.CancelEvents())
+}
+
+func TestChatToResponsesStreamLastUsage(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	require.Nil(t, state.LastUsage())
+
+	for i, completion := range []int{1, 2, 5} {
+		content := "x"
+		state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+			Usage:   &dto.Usage{PromptTokens: 7, CompletionTokens: completion, TotalTokens: 7 + completion},
+		})
+		require.NotNil(t, state.LastUsage(), "chunk %d", i)
+		require.Equal(t, completion, state.LastUsage().CompletionTokens)
+	}
+
+	final := state.FinalEvents(nil)
+	completed := final[len(final)-1]
+	require.Equal(t, 7, completed.Response.Usage.InputTokens)
+	require.Equal(t, 5, completed.Response.Usage.OutputTokens)
+	require.Equal(t, 12, completed.Response.Usage.TotalTokens)
+}
+
+func TestChatToResponsesStreamUsageDetails(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	content := "Hi"
+	state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+		Usage: &dto.Usage{
+			PromptTokens:           20,
+			CompletionTokens:       9,
+			PromptTokensDetails:    dto.InputTokenDetails{CachedTokens: 16},
+			CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: 6},
+		},
+	})
+	final := state.FinalEvents(nil)
+	usage := final[len(final)-1].Response.Usage
+	require.Equal(t, &dto.InputTokenDetails{CachedTokens: 16}, usage.InputTokensDetails)
+	require.Equal(t, &dto.OutputTokenDetails{ReasoningTokens: 6}, usage.OutputTokensDetails)
+
+	// Both detail blocks are present even when the upstream reports none.
+	state = NewChatToResponsesStreamState("chatcmpl-2", 1700000000, "gpt-4.1")
+	state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+		Usage:   &dto.Usage{PromptTokens: 5, CompletionTokens: 1},
+	})
+	final = state.FinalEvents(nil)
+	usage = final[len(final)-1].Response.Usage
+	require.Equal(t, &dto.InputTokenDetails{}, usage.InputTokensDetails)
+	require.Equal(t, &dto.OutputTokenDetails{}, usage.OutputTokensDetails)
+
+	chatResp := &dto.OpenAITextResponse{
+		Id:    "chatcmpl-3",
+		Model: "gpt-4.1",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "Hi"}, FinishReason: "stop"},
+		},
+		Usage: dto.Usage{PromptTokens: 5, CompletionTokens: 1, TotalTokens: 6},
+	}
+	resp, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	data, err := common.Marshal(resp.Usage)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"input_tokens_details":{"cached_tokens":0`)
+	require.Contains(t, string(data), `"output_tokens_details":{`)
+	require.Contains(t, string(data), `"reasoning_tokens":0`)
+}
+
+func TestChatToResponsesStreamParsedJSONOutput(t *testing.T) {
+	require.True(t, UsesJSONSchemaTextFormat([]byte(`{"format":{"type":"json_schema","name":"city","schema":{"type":"object"}}}`)))
+	require.False(t, UsesJSONSchemaTextFormat([]byte(`{"format":{"type":"text"}}`)))
+
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	state.ParseJSONOutput = true
+
+	var deltas []string
+	for _, piece := range []string{`{"city":`, `"Paris",`, `"population":2100000}`} {
+		content := piece
+		for _, event := range state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+		}) {
+			if event.Type == "response.output_text.delta" {
+				deltas = append(deltas, event.Delta)
+			}
+		}
+	}
+	require.Len(t, deltas, 3)
+
+	var done *dto.ResponsesOutput
+	for _, event := range state.FinalEvents(nil) {
+		if event.Type == "response.output_item.done" && event.Item != nil && event.Item.Type == "message" {
+			done = event.Item
+		}
+	}
+	require.NotNil(t, done)
+	require.JSONEq(t, `{"city":"Paris","population":2100000}`, string(done.Content[0].Parsed))
+	require.Equal(t, `{"city":"Paris","population":2100000}`, done.Content[0].Text)
+}
+
+func TestChatToResponsesStreamReasoningItemIndex(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "deepseek-reasoner")
+
+	text := "Answer"
+	reasoning := "Thinking"
+	var events []dto.ResponsesStreamResponse
+	// Text arrives first, then reasoning interleaves with more text.
+	events = append(events, state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &text}}},
+	})...)
+	events = append(events, state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: &reasoning}}},
+	})...)
+	events = append(events, state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: &reasoning}}},
+	})...)
+	events = append(events, state.FinalEvents(nil)...)
+
+	addedIndex := map[string]int{}
+	var reasoningDeltaIndexes []int
+	for _, event := range events {
+		switch event.Type {
+		case "response.output_item.added":
+			addedIndex[event.Item.Type] = *event.OutputIndex
+		case "response.reasoning_summary_text.delta":
+			require.Contains(t, addedIndex, "reasoning", "reasoning item must be added before its first delta")
+			reasoningDeltaIndexes = append(reasoningDeltaIndexes, *event.OutputIndex)
+		}
+	}
+	require.Equal(t, 0, addedIndex["message"])
+	require.Equal(t, 1, addedIndex["reasoning"])
+	require.Equal(t, []int{1, 1}, reasoningDeltaIndexes)
+
+	completed := events[len(events)-1]
+	require.Equal(t, "response.completed", completed.Type)
+	require.Len(t, completed.Response.Output, 2)
+	require.Equal(t, "message", completed.Response.Output[0].Type)
+	require.Equal(t, "reasoning", completed.Response.Output[1].Type)
+	require.Equal(t, "ThinkingThinking", completed.Response.Output[1].Summary[0].Text)
+}
+
+func TestChatToResponsesStreamLateToolCallName(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"arguments":"{\"ci"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"clock","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"lookup","arguments":"ty\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		// The ID of the third call only arrives with its name.
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":2,"function":{"arguments":"{\"q\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":2,"id":"call_c","function":{"name":"search","arguments":"1}"}}]}}]}`,
+	}
+
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	var events []dto.ResponsesStreamResponse
+	for _, raw := range chunks {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		events = append(events, state.HandleChatChunk(&chunk)...)
+	}
+	events = append(events, state.FinalEvents(nil)...)
+
+	added := 0
+	doneNames := map[string]string{}
+	for _, event := range events {
+		switch event.Type {
+		case "response.output_item.added":
+			added++
+		case "response.output_item.done":
+			doneNames[event.Item.CallId] = event.Item.Name
+		}
+	}
+	require.Equal(t, 3, added)
+	require.Len(t, doneNames, 3)
+	require.Equal(t, "lookup", doneNames["call_a"])
+	require.Equal(t, "clock", doneNames["call_b"])
+
+	output := events[len(events)-1].Response.Output
+	require.Len(t, output, 3)
+	require.Equal(t, "lookup", output[0].Name)
+	require.Equal(t, `{"city":"Paris"}`, output[0].ArgumentsString())
+	require.Equal(t, "clock", output[1].Name)
+	require.Equal(t, "search", output[2].Name)
+	require.Equal(t, `{"q":1}`, output[2].ArgumentsString())
+}
+
+func TestChatToResponsesStreamItemOrderAfterReasoning(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"reasoning_content":"Thinking."}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"Done."}}]}`,
+	}
+
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	var events []dto.ResponsesStreamResponse
+	for _, raw := range chunks {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		events = append(events, state.HandleChatChunk(&chunk)...)
+	}
+	require.Empty(t, events[0].Response.Output)
+	final := state.FinalEvents(nil)
+
+	var addedIDs, doneIDs []string
+	var addedIndexes, doneIndexes []int
+	for _, event := range append(events, final...) {
+		switch event.Type {
+		case "response.output_item.added":
+			addedIDs = append(addedIDs, event.ItemID)
+			addedIndexes = append(addedIndexes, *event.OutputIndex)
+		case "response.output_item.done":
+			doneIDs = append(doneIDs, event.ItemID)
+			doneIndexes = append(doneIndexes, *event.OutputIndex)
+		}
+	}
+	require.Equal(t, []int{0, 1, 2}, addedIndexes)
+	require.Equal(t, []int{0, 1, 2}, doneIndexes)
+	require.Equal(t, addedIDs, doneIDs)
+	require.Equal(t, "call_1", addedIDs[1])
+	require.Equal(t, state.MessageItemID, addedIDs[2])
+
+	output := final[len(final)-1].Response.Output
+	require.Len(t, output, 3)
+	require.Equal(t, []string{"reasoning", "function_call", "message"}, []string{output[0].Type, output[1].Type, output[2].Type})
+}
+
+func TestChatToResponsesStreamCombinedFinalChunk(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	state.IncludeFinishReason = true
+
+	var first dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`, &first))
+	state.HandleChatChunk(&first)
+
+	// One chunk carries the last delta, the finish reason and the usage.
+	var last dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}
+	}`, &last))
+	events := state.HandleChatChunk(&last)
+	require.Len(t, events, 1)
+	require.Equal(t, "lo", events[0].Delta)
+	require.Equal(t, "stop", state.FinishReason)
+	require.Equal(t, 11, state.LastUsage().TotalTokens)
+
+	final := state.FinalEvents(nil)
+	completed := final[len(final)-1].Response
+	require.Equal(t, "Hello", completed.Output[0].Content[0].Text)
+	require.Equal(t, 9, completed.Usage.InputTokens)
+	require.Equal(t, 2, completed.Usage.OutputTokens)
+	require.JSONEq(t, `{"finish_reason":"stop"}`, string(completed.Metadata))
+}
+
+func TestChatToResponsesStreamTruncatedToolArguments(t *testing.T) {
+	run := func(policy TruncatedToolArgumentsPolicy) dto.ResponsesOutput {
+		state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+		state.TruncatedToolArguments = policy
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"city\":\"Par"}}]}}]}`, &chunk))
+		state.HandleChatChunk(&chunk)
+		final := state.FinalEvents(nil)
+		var doneItem *dto.ResponsesOutput
+		for _, event := range final {
+			if event.Type == "response.output_item.done" {
+				doneItem = event.Item
+			}
+		}
+		output := final[len(final)-1].Response.Output
+		require.Len(t, output, 1)
+		require.Equal(t, *doneItem, output[0])
+		return output[0]
+	}
+
+	item := run(TruncatedToolArgumentsKeep)
+	require.Equal(t, "completed", item.Status)
+	require.Equal(t, `{"city":"Par`, string(item.Arguments))
+
+	item = run(TruncatedToolArgumentsRepair)
+	require.Equal(t, "completed", item.Status)
+	require.Equal(t, `{"city":"Par"}`, string(item.Arguments))
+
+	item = run(TruncatedToolArgumentsMarkIncomplete)
+	require.Equal(t, "incomplete", item.Status)
+	require.Equal(t, `{"city":"Par`, string(item.Arguments))
+}
+
+func TestChatToResponsesStreamItemTimestamps(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	clock := int64(1700000001)
+	state.now = func() int64 { return clock }
+
+	var chunk dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{"choices":[{"index":0,"delta":{"content":"Looking it up."}}]}`, &chunk))
+	events := state.HandleChatChunk(&chunk)
+	clock = 1700000003
+	require.NoError(t, common.UnmarshalJsonStr(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]}}]}`, &chunk))
+	events = append(events, state.HandleChatChunk(&chunk)...)
+	clock = 1700000004
+	events = append(events, state.FinalEvents(nil)...)
+
+	added := make(map[string]*dto.ResponsesOutput)
+	done := make(map[string]*dto.ResponsesOutput)
+	for _, event := range events {
+		switch event.Type {
+		case "response.output_item.added":
+			added[event.Item.ID] = event.Item
+		case "response.output_item.done":
+			done[event.Item.ID] = event.Item
+		}
+	}
+	message := added[state.MessageItemID]
+	require.Equal(t, int64(1700000001), *message.CreatedAt)
+	require.Nil(t, message.CompletedAt)
+	require.Equal(t, int64(1700000003), *added["call_1"].CreatedAt)
+
+	require.Equal(t, int64(1700000001), *done[state.MessageItemID].CreatedAt)
+	require.Equal(t, int64(1700000004), *done[state.MessageItemID].CompletedAt)
+	require.Equal(t, int64(1700000003), *done["call_1"].CreatedAt)
+	require.Equal(t, int64(1700000004), *done["call_1"].CompletedAt)
+
+	output := events[len(events)-1].Response.Output
+	require.Len(t, output, 2)
+	require.Equal(t, *done[state.MessageItemID], output[0])
+	require.Equal(t, *done["call_1"], output[1])
+}
+
+func TestChatToResponsesStreamAnnotations(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4o-search-preview")
+
+	var events []dto.ResponsesStreamResponse
+	for _, raw := range []string{
+		`{"choices":[{"index":0,"delta":{"content":"Paris is sunny."}}]}`,
+		`{"choices":[{"index":0,"delta":{"annotations":[{"type":"url_citation","url_citation":{"url":"https://weather.example/paris","title":"Paris","start_index":0,"end_index":15}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":" Lyon too.","annotations":[{"type":"url_citation","url_citation":{"url":"https://weather.example/lyon","title":"Lyon","start_index":16,"end_index":26}}]}}]}`,
+	} {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		events = append(events, state.HandleChatChunk(&chunk)...)
+	}
+	events = append(events, state.FinalEvents(nil)...)
+
+	var added []dto.ResponsesStreamResponse
+	var partDone *dto.ResponsesStreamResponse
+	for i, event := range events {
+		switch event.Type {
+		case "response.output_text.annotation.added":
+			added = append(added, event)
+		case "response.content_part.done":
+			partDone = &events[i]
+		}
+	}
+	require.Len(t, added, 2)
+	for i, event := range added {
+		require.Equal(t, state.MessageItemID, event.ItemID)
+		require.Equal(t, 0, *event.OutputIndex)
+		require.Equal(t, 0, *event.ContentIndex)
+		require.Equal(t, i, *event.AnnotationIndex)
+	}
+	require.Equal(t, "https://weather.example/lyon", added[1].Annotation.(map[string]any)["url"])
+
+	require.NotNil(t, partDone)
+	require.Equal(t, "Paris is sunny. Lyon too.", partDone.Part.Text)
+	require.Equal(t, []interface{}{added[0].Annotation, added[1].Annotation}, partDone.Part.Annotations)
+	final := events[len(events)-1].Response
+	require.Equal(t, partDone.Part.Annotations, final.Output[0].Content[0].Annotations)
+}
+
+func TestChatToResponsesStreamThinkingDelta(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "deepseek-r1")
+
+	var deltas []string
+	for _, raw := range []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","thinking":"Compare "}}]}`,
+		`{"choices":[{"index":0,"delta":{"thinking":"both cities."}}]}`,
+		`{"choices":[{"index":0,"delta":{"thinking":{"signature":"sig"}}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"Lyon."}}]}`,
+	} {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		for _, event := range state.HandleChatChunk(&chunk) {
+			if event.Type == "response.reasoning_summary_text.delta" {
+				deltas = append(deltas, event.Delta)
+			}
+		}
+	}
+	final := state.FinalEvents(nil)
+	output := final[len(final)-1].Response.Output
+
+	require.Equal(t, []string{"Compare ", "both cities."}, deltas)
+	require.Len(t, output, 2)
+	require.Equal(t, "reasoning", output[0].Type)
+	require.Equal(t, "Compare both cities.", output[0].Summary[0].Text)
+	require.Equal(t, "Lyon.", output[1].Content[0].Text)
+}
+
+func TestChatToResponsesStreamHalfFormedToolCallsIncomplete(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	for _, raw := range []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_ok","type":"function","function":{"name":"lookup","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_cut","type":"function","function":{"name":"lookup","arguments":"{\"city\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":2,"id":"call_empty","type":"function","function":{"name":"lookup"}}]}}]}`,
+	} {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		state.HandleChatChunk(&chunk)
+	}
+	final := state.FinalEvents(nil)
+
+	statuses := make(map[string]string)
+	for _, event := range final {
+		if event.Type == "response.output_item.done" {
+			statuses[event.Item.CallId] = event.Item.Status
+		}
+	}
+	expected := map[string]string{"call_ok": "completed", "call_cut": "incomplete", "call_empty": "incomplete"}
+	require.Equal(t, expected, statuses)
+	for _, item := range final[len(final)-1].Response.Output {
+		require.Equal(t, expected[item.CallId], item.Status)
+	}
+}
+
+func TestChatToResponsesStreamToolCallWithoutArguments(t *testing.T) {
+	run := func(policy TruncatedToolArgumentsPolicy, chunks ...string) dto.ResponsesOutput {
+		state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+		state.TruncatedToolArguments = policy
+		for _, raw := range chunks {
+			var chunk dto.ChatCompletionsStreamResponse
+			require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+			state.HandleChatChunk(&chunk)
+		}
+		final := state.FinalEvents(nil)
+		output := final[len(final)-1].Response.Output
+		require.Len(t, output, 1)
+		return output[0]
+	}
+	nameOnly := `{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"now"}}]}}]}`
+	finished := `{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`
+
+	// A parameterless call in a finished stream is complete.
+	item := run(TruncatedToolArgumentsMarkIncomplete, nameOnly, finished)
+	require.Equal(t, "completed", item.Status)
+	require.Equal(t, "{}", string(item.Arguments))
+
+	// A stream cut off right after the name leaves the call incomplete.
+	for _, policy := range []TruncatedToolArgumentsPolicy{TruncatedToolArgumentsMarkIncomplete, TruncatedToolArgumentsRepair} {
+		item = run(policy, nameOnly)
+		require.Equal(t, "incomplete", item.Status)
+		require.Empty(t, item.Arguments)
+	}
+}
+
+func TestChatToResponsesStreamToolCallIDAfterFirstDelta(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	var events []dto.ResponsesStreamResponse
+	for _, raw := range []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_real","function":{"arguments":"\"x\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	} {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		events = append(events, state.HandleChatChunk(&chunk)...)
+	}
+	events = append(events, state.FinalEvents(nil)...)
+
+	var added, done *dto.ResponsesOutput
+	for _, event := range events {
+		switch event.Type {
+		case "response.output_item.added":
+			require.Nil(t, added, "the call must be added once")
+			added = event.Item
+		case "response.output_item.done":
+			done = event.Item
+		}
+	}
+	require.NotNil(t, added)
+	require.NotNil(t, done)
+	// The item keeps the ID it was added with, but the final call_id is the
+	// one the upstream issued.
+	require.Equal(t, added.ID, done.ID)
+	require.Equal(t, "call_real", done.CallId)
+	require.Equal(t, `{"q":"x"}`, string(done.Arguments))
+
+	output := events[len(events)-1].Response.Output
+	require.Len(t, output, 1)
+	require.Equal(t, "call_real", output[0].CallId)
+}
+
+func TestChatToResponsesStreamArrayContentDelta(t *testing.T) {
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	var events []dto.ResponsesStreamResponse
+	for _, raw := range []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":[{"type":"text","text":"Here is the chart: "},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":" Done."},"finish_reason":"stop"}]}`,
+	} {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		events = append(events, state.HandleChatChunk(&chunk)...)
+	}
+	events = append(events, state.FinalEvents(nil)...)
+
+	var deltas []string
+	partsAdded := 0
+	for _, event := range events {
+		switch event.Type {
+		case "response.output_text.delta":
+			deltas = append(deltas, event.Delta)
+		case "response.content_part.added":
+			partsAdded++
+		}
+	}
+	require.Equal(t, 1, partsAdded)
+	require.Equal(t, []string{"Here is the chart: ", "![image](data:image/png;base64,iVBORw0KGgo=)", " Done."}, deltas)
+
+	output := events[len(events)-1].Response.Output
+	require.Len(t, output, 1)
+	require.Equal(t, "Here is the chart: ![image](data:image/png;base64,iVBORw0KGgo=) Done.", output[0].Content[0].Text)
+
+	// The array survives a decode/encode round trip.
+	var chunk dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{"choices":[{"index":0,"delta":{"content":[{"type":"text","text":"hi"}]}}]}`, &chunk))
+	require.Nil(t, chunk.Choices[0].Delta.Content)
+	data, err := common.Marshal(chunk.Choices[0].Delta)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"content":[{"type":"text","text":"hi"}]}`, string(data))
+}
@@ -36,10 +36,52 @@ func normalizeChatImageURLToString(v any) any {
 	}
 }
 
-func convertChatResponseFormatToResponsesText(reqFormat *dto.ResponseFormat) json.RawMessage {
-	if reqFormat == nil || strings.TrimSpace(reqFormat.Type) == "" {
+const (
+	responsesIncludeCodeInterpreterOutputs = "code_interpreter_call.outputs"
+	responsesIncludeOutputTextLogprobs     = "message.output_text.logprobs"
+	responsesIncludeFileSearchResults      = "file_search_call.results"
+)
+
+// parseResponsesInclude returns the values of a Responses `include` array,
+// ignoring malformed input.
+func parseResponsesInclude(includeRaw json.RawMessage) []string {
+	if len(includeRaw) == 0 || common.GetJsonType(includeRaw) != "array" {
 		return nil
 	}
+	var include []string
+	if err := common.Unmarshal(includeRaw, &include); err != nil {
+		return nil
+	}
+	return include
+}
+
+// appendResponsesInclude adds value to the `include` array unless it is
+// already present.
+func appendResponsesInclude(includeRaw json.RawMessage, value string) json.RawMessage {
+	include := parseResponsesInclude(includeRaw)
+	if lo.Contains(include, value) {
+		return includeRaw
+	}
+	include = append(include, value)
+	b, err := common.Marshal(include)
+	if err != nil {
+		return includeRaw
+	}
+	return b
+}
+
+func convertChatResponseFormatToResponsesText(reqFormat *dto.ResponseFormat, verbosity json.RawMessage) json.RawMessage {
+	text := map[string]any{}
+	if len(verbosity) > 0 && common.GetJsonType(verbosity) != "null" {
+		text["verbosity"] = verbosity
+	}
+	if reqFormat == nil || strings.TrimSpace(reqFormat.Type) == "" {
+		if len(text) == 0 {
+			return nil
+		}
+		textRaw, _ := common.Marshal(text)
+		return textRaw
+	}
 
 	format := map[string]any{
 		"type": reqFormat.Type,
@@ -68,13 +110,88 @@ func convertChatResponseFormatToResponsesText(reqFormat *dto.ResponseFormat) jso
 		}
 	}
 
-	textRaw, _ := common.Marshal(map[string]any{
-		"format": format,
-	})
+	text["format"] = format
+	textRaw, _ := common.Marshal(text)
 	return textRaw
 }
 
+// chatToolCallsToResponsesItems converts assistant tool calls into Responses
+// function_call / custom_tool_call input items, recording custom call IDs so
+// the matching tool results can be typed accordingly.
+func chatToolCallsToResponsesItems(toolCalls []dto.ToolCallRequest, customCallIDs map[string]bool) []map[string]any {
+	items := make([]map[string]any, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		if strings.TrimSpace(tc.ID) == "" {
+			continue
+		}
+		name := strings.TrimSpace(tc.Function.Name)
+		if name == "" {
+			continue
+		}
+		switch tc.Type {
+		case "", "function":
+			items = append(items, map[string]any{
+				"type":      "function_call",
+				"call_id":   tc.ID,
+				"name":      name,
+				"arguments": tc.Function.Arguments,
+			})
+		case "custom":
+			customCallIDs[tc.ID] = true
+			items = append(items, map[string]any{
+				"type":    "custom_tool_call",
+				"call_id": tc.ID,
+				"name":    name,
+				"input":   tc.Function.Arguments,
+			})
+		}
+	}
+	return items
+}
+
+// responsesCustomTool flattens a Chat custom tool definition into the
+// Responses shape, where the grammar syntax and definition sit directly on
+// the format object.
+func responsesCustomTool(custom *dto.CustomToolRequest) map[string]any {
+	tool := map[string]any{
+		"type": "custom",
+		"name": custom.Name,
+	}
+	if custom.Description != "" {
+		tool["description"] = custom.Description
+	}
+	if custom.Format != nil {
+		format := map[string]any{"type": custom.Format.Type}
+		if custom.Format.Grammar != nil {
+			format["syntax"] = custom.Format.Grammar.Syntax
+			format["definition"] = custom.Format.Grammar.Definition
+		}
+		tool["format"] = format
+	}
+	return tool
+}
+
+// ChatToResponsesOptions tunes ChatCompletionsRequestToResponsesRequestWithOptions.
+type ChatToResponsesOptions struct {
+	// ReasoningSummary sets reasoning.summary ("auto", "concise" or
+	// "detailed") on the produced request. Chat has no equivalent field, so
+	// callers proxying to a Responses-native backend choose it here. Empty
+	// leaves the field unset.
+	ReasoningSummary string
+}
+
+// ChatCompletionsRequestToResponsesRequest converts a Chat Completions request
+// to a Responses API request, asking for detailed reasoning summaries whenever
+// a reasoning effort is set so they can be streamed back as reasoning_content.
 func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*dto.OpenAIResponsesRequest, error) {
+	opts := ChatToResponsesOptions{}
+	if req != nil && req.ReasoningEffort != "" {
+		opts.ReasoningSummary = "detailed"
+	}
+	return ChatCompletionsRequestToResponsesRequestWithOptions(req, opts)
+}
+
+func ChatCompletionsRequestToResponsesRequestWithOptions(req *dto.GeneralOpenAIRequest, opts ChatToResponsesOptions) (*dto.OpenAIResponsesRequest, error) {
 	if req == nil {
 		return nil, errors.New(i18n.Translate("svc.request_is_nil_827d"))
 	}
@@ -87,6 +204,9 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 
 	var instructionsParts []string
 	inputItems := make([]map[string]any, 0, len(req.Messages))
+	// Tool results answering a custom tool call must be sent back as
+	// custom_tool_call_output rather than function_call_output.
+	customCallIDs := make(map[string]bool)
 
 	for _, msg := range req.Messages {
 		role := strings.TrimSpace(msg.Role)
@@ -118,16 +238,24 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 				continue
 			}
 
+			outputType := "function_call_output"
+			if customCallIDs[callID] {
+				outputType = "custom_tool_call_output"
+			}
 			inputItems = append(inputItems, map[string]any{
-				"type":    "function_call_output",
+				"type":    outputType,
 				"call_id": callID,
 				"output":  output,
 			})
 			continue
 		}
 
+		name := strings.TrimSpace(lo.FromPtr(msg.Name))
+
 		// Prefer mapping system/developer messages into `instructions`.
-		if role == "system" || role == "developer" {
+		// Named ones, and ones carrying vendor extensions, stay input items
+		// as instructions cannot carry either.
+		if (role == "system" || role == "developer") && name == "" && len(msg.Extensions) == 0 {
 			if msg.Content == nil {
 				continue
 			}
@@ -156,30 +284,24 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 		item := map[string]any{
 			"role": role,
 		}
+		if name != "" {
+			item["name"] = name
+		}
+		if msg.Status != "" {
+			item["status"] = msg.Status
+		}
+		for key, value := range msg.Extensions {
+			if _, exists := item[key]; !exists {
+				item[key] = value
+			}
+		}
 
 		if msg.Content == nil {
 			item["content"] = ""
 			inputItems = append(inputItems, item)
 
 			if role == "assistant" {
-				for _, tc := range msg.ParseToolCalls() {
-					if strings.TrimSpace(tc.ID) == "" {
-						continue
-					}
-					if tc.Type != "" && tc.Type != "function" {
-						continue
-					}
-					name := strings.TrimSpace(tc.Function.Name)
-					if name == "" {
-						continue
-					}
-					inputItems = append(inputItems, map[string]any{
-						"type":      "function_call",
-						"call_id":   tc.ID,
-						"name":      name,
-						"arguments": tc.Function.Arguments,
-					})
-				}
+				inputItems = append(inputItems, chatToolCallsToResponsesItems(orderedChatToolCalls(&msg), customCallIDs)...)
 			}
 			continue
 		}
@@ -189,24 +311,7 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 			inputItems = append(inputItems, item)
 
 			if role == "assistant" {
-				for _, tc := range msg.ParseToolCalls() {
-					if strings.TrimSpace(tc.ID) == "" {
-						continue
-					}
-					if tc.Type != "" && tc.Type != "function" {
-						continue
-					}
-					name := strings.TrimSpace(tc.Function.Name)
-					if name == "" {
-						continue
-					}
-					inputItems = append(inputItems, map[string]any{
-						"type":      "function_call",
-						"call_id":   tc.ID,
-						"name":      name,
-						"arguments": tc.Function.Arguments,
-					})
-				}
+				inputItems = append(inputItems, chatToolCallsToResponsesItems(orderedChatToolCalls(&msg), customCallIDs)...)
 			}
 			continue
 		}
@@ -225,20 +330,47 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 					"text": part.Text,
 				})
 			case dto.ContentTypeImageURL:
-				contentParts = append(contentParts, map[string]any{
+				imagePart := map[string]any{
 					"type":      "input_image",
 					"image_url": normalizeChatImageURLToString(part.ImageUrl),
-				})
+				}
+				if img := part.GetImageMedia(); img != nil && img.FileId != "" {
+					imagePart["file_id"] = img.FileId
+					if img.Url == "" {
+						delete(imagePart, "image_url")
+					}
+					if img.Detail != "" {
+						imagePart["detail"] = img.Detail
+					}
+				}
+				contentParts = append(contentParts, imagePart)
 			case dto.ContentTypeInputAudio:
-				contentParts = append(contentParts, map[string]any{
-					"type":        "input_audio",
-					"input_audio": part.InputAudio,
-				})
+				audioPart := map[string]any{"type": "input_audio"}
+				if audio := part.GetInputAudio(); audio != nil {
+					audioPart["data"] = audio.Data
+					audioPart["format"] = audio.Format
+				} else {
+					audioPart["input_audio"] = part.InputAudio
+				}
+				contentParts = append(contentParts, audioPart)
 			case dto.ContentTypeFile:
-				contentParts = append(contentParts, map[string]any{
-					"type": "input_file",
-					"file": part.File,
-				})
+				filePart := map[string]any{"type": "input_file"}
+				if file := part.GetFile(); file != nil {
+					if file.FileName != "" {
+						filePart["filename"] = file.FileName
+					}
+					switch {
+					case file.FileId != "":
+						filePart["file_id"] = file.FileId
+					case file.FileData == "" && file.FileUrl != "":
+						filePart["file_url"] = file.FileUrl
+					default:
+						filePart["file_data"] = file.FileData
+					}
+				} else {
+					filePart["file"] = part.File
+				}
+				contentParts = append(contentParts, filePart)
 			case dto.ContentTypeVideoUrl:
 				contentParts = append(contentParts, map[string]any{
 					"type":      "input_video",
@@ -254,24 +386,7 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 		inputItems = append(inputItems, item)
 
 		if role == "assistant" {
-			for _, tc := range msg.ParseToolCalls() {
-				if strings.TrimSpace(tc.ID) == "" {
-					continue
-				}
-				if tc.Type != "" && tc.Type != "function" {
-					continue
-				}
-				name := strings.TrimSpace(tc.Function.Name)
-				if name == "" {
-					continue
-				}
-				inputItems = append(inputItems, map[string]any{
-					"type":      "function_call",
-					"call_id":   tc.ID,
-					"name":      name,
-					"arguments": tc.Function.Arguments,
-				})
-			}
+			inputItems = append(inputItems, chatToolCallsToResponsesItems(orderedChatToolCalls(&msg), customCallIDs)...)
 		}
 	}
 
@@ -287,22 +402,52 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 	}
 
 	var toolsRaw json.RawMessage
+	var includeRaw json.RawMessage
 	if req.Tools != nil {
 		tools := make([]map[string]any, 0, len(req.Tools))
 		for _, tool := range req.Tools {
 			switch tool.Type {
 			case "function":
-				tools = append(tools, map[string]any{
+				fn := map[string]any{
 					"type":        "function",
 					"name":        tool.Function.Name,
 					"description": tool.Function.Description,
 					"parameters":  tool.Function.Parameters,
-				})
+				}
+				if tool.Function.Strict != nil {
+					fn["strict"] = *tool.Function.Strict
+				}
+				tools = append(tools, fn)
+			case "custom":
+				if custom := tool.GetCustomTool(); custom != nil {
+					tools = append(tools, responsesCustomTool(custom))
+					break
+				}
+				fallthrough
 			default:
-				// Best-effort: keep original tool shape for unknown types.
+				if tool.Type == "code_interpreter" {
+					// Ask upstream to return the interpreter artifacts so they
+					// can be surfaced back to the Chat client.
+					includeRaw = appendResponsesInclude(includeRaw, responsesIncludeCodeInterpreterOutputs)
+				}
+				if tool.Type == "file_search" {
+					// Results back the file_citation annotations on the Chat message.
+					includeRaw = appendResponsesInclude(includeRaw, responsesIncludeFileSearchResults)
+				}
+				// Best-effort: keep original tool shape for unknown types. Tools
+				// carried over from a Responses request (mcp, file_search, ...)
+				// hold their full definition in Custom.
 				var m map[string]any
-				if b, err := common.Marshal(tool); err == nil {
-					_ = common.Unmarshal(b, &m)
+				if len(tool.Custom) > 0 {
+					_ = common.Unmarshal(tool.Custom, &m)
+					if t, _ := m["type"].(string); t != tool.Type {
+						m = nil
+					}
+				}
+				if len(m) == 0 {
+					if b, err := common.Marshal(tool); err == nil {
+						_ = common.Unmarshal(b, &m)
+					}
 				}
 				if len(m) == 0 {
 					m = map[string]any{"type": tool.Type}
@@ -325,28 +470,8 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 			}
 			if m == nil {
 				toolChoiceRaw, _ = common.Marshal(v)
-			} else if t, _ := m["type"].(string); t == "function" {
-				// Chat: {"type":"function","function":{"name":"..."}}
-				// Responses: {"type":"function","name":"..."}
-				if name, ok := m["name"].(string); ok && name != "" {
-					toolChoiceRaw, _ = common.Marshal(map[string]any{
-						"type": "function",
-						"name": name,
-					})
-				} else if fn, ok := m["function"].(map[string]any); ok {
-					if name, ok := fn["name"].(string); ok && name != "" {
-						toolChoiceRaw, _ = common.Marshal(map[string]any{
-							"type": "function",
-							"name": name,
-						})
-					} else {
-						toolChoiceRaw, _ = common.Marshal(v)
-					}
-				} else {
-					toolChoiceRaw, _ = common.Marshal(v)
-				}
 			} else {
-				toolChoiceRaw, _ = common.Marshal(v)
+				toolChoiceRaw, _ = common.Marshal(convertChatToolChoice(m))
 			}
 		}
 	}
@@ -356,7 +481,7 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 		parallelToolCallsRaw, _ = common.Marshal(*req.ParallelToolCalls)
 	}
 
-	textRaw := convertChatResponseFormatToResponsesText(req.ResponseFormat)
+	textRaw := convertChatResponseFormatToResponsesText(req.ResponseFormat, req.Verbosity)
 
 	maxOutputTokens := lo.FromPtrOr(req.MaxTokens, uint(0))
 	maxCompletionTokens := lo.FromPtrOr(req.MaxCompletionTokens, uint(0))
@@ -376,6 +501,7 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 	out := &dto.OpenAIResponsesRequest{
 		Model:             req.Model,
 		Input:             inputRaw,
+		Include:           includeRaw,
 		Instructions:      instructionsRaw,
 		Stream:            req.Stream,
 		Temperature:       req.Temperature,
@@ -384,20 +510,74 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 		Tools:             toolsRaw,
 		TopP:              topP,
 		User:              req.User,
+		SafetyIdentifier:  req.SafetyIdentifier,
 		ParallelToolCalls: parallelToolCallsRaw,
 		Store:             req.Store,
 		Metadata:          req.Metadata,
+		Truncation:        req.Truncation,
 	}
 	if req.MaxTokens != nil || req.MaxCompletionTokens != nil {
 		out.MaxOutputTokens = lo.ToPtr(maxOutputTokens)
 	}
+	if req.MaxToolCalls != nil {
+		out.MaxToolCalls = lo.ToPtr(*req.MaxToolCalls)
+	}
+	if req.PromptCacheKey != "" {
+		out.PromptCacheKey, _ = common.Marshal(req.PromptCacheKey)
+	}
+	if len(req.ServiceTier) > 0 {
+		var serviceTier string
+		if err := common.Unmarshal(req.ServiceTier, &serviceTier); err == nil {
+			out.ServiceTier = serviceTier
+		}
+	}
 
-	if req.ReasoningEffort != "" {
+	if req.ReasoningEffort != "" || opts.ReasoningSummary != "" {
 		out.Reasoning = &dto.Reasoning{
 			Effort:  req.ReasoningEffort,
-			Summary: "detailed",
+			Summary: opts.ReasoningSummary,
 		}
 	}
 
 	return out, nil
 }
+
+// convertChatToolChoice translates an object tool_choice into its Responses
+// form, the inverse of convertResponsesToolChoice. Choices that already use
+// the flat Responses shape or that have no Responses form are passed through.
+func convertChatToolChoice(m map[string]any) any {
+	toolType, _ := m["type"].(string)
+	switch toolType {
+	case "function", "custom":
+		// Chat:      {"type": "function", "function": {"name": "fn_name"}}
+		// Responses: {"type": "function", "name": "fn_name"}
+		if name, ok := m["name"].(string); ok && name != "" {
+			return map[string]any{"type": toolType, "name": name}
+		}
+		if nested, ok := m[toolType].(map[string]any); ok {
+			if name, ok := nested["name"].(string); ok && name != "" {
+				return map[string]any{"type": toolType, "name": name}
+			}
+		}
+	case "allowed_tools":
+		allowed, ok := m["allowed_tools"].(map[string]any)
+		if !ok {
+			return m
+		}
+		rawTools, _ := allowed["tools"].([]any)
+		tools := make([]any, 0, len(rawTools))
+		for _, rawTool := range rawTools {
+			if tool, ok := rawTool.(map[string]any); ok {
+				tools = append(tools, convertChatToolChoice(tool))
+				continue
+			}
+			tools = append(tools, rawTool)
+		}
+		return map[string]any{
+			"type":  "allowed_tools",
+			"mode":  allowed["mode"],
+			"tools": tools,
+		}
+	}
+	return m
+}
@@ -0,0 +1,632 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatToResponsesRequestReasoningSummary(t *testing.T) {
+	req := &dto.GeneralOpenAIRequest{
+		Model:           "o3",
+		Messages:        []dto.Message{{Role: "user", Content: "hi"}},
+		ReasoningEffort: "high",
+	}
+
+	out, err := ChatCompletionsRequestToResponsesRequestWithOptions(req, ChatToResponsesOptions{ReasoningSummary: "concise"})
+	require.NoError(t, err)
+	require.NotNil(t, out.Reasoning)
+	require.Equal(t, "high", out.Reasoning.Effort)
+	require.Equal(t, "concise", out.Reasoning.Summary)
+
+	out, err = ChatCompletionsRequestToResponsesRequestWithOptions(req, ChatToResponsesOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, out.Reasoning)
+	require.Empty(t, out.Reasoning.Summary)
+}
+
+func TestInputItemStatusRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`[
+			{"role":"user","content":"write a poem"},
+			{"role":"assistant","status":"incomplete","content":[{"type":"output_text","text":"Roses are"}]}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 2)
+	require.Equal(t, "incomplete", chatReq.Messages[1].Status)
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	var items []map[string]any
+	require.NoError(t, common.Unmarshal(back.Input, &items))
+	require.Len(t, items, 2)
+	require.NotContains(t, items[0], "status")
+	require.Equal(t, "incomplete", items[1]["status"])
+}
+
+func TestFunctionToolStrictRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`"weather?"`),
+		Tools: []byte(`[
+			{"type":"function","name":"get_weather","strict":true,"parameters":{"type":"object"}},
+			{"type":"function","name":"get_time","parameters":{"type":"object"}}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Tools, 2)
+	require.NotNil(t, chatReq.Tools[0].Function.Strict)
+	require.True(t, *chatReq.Tools[0].Function.Strict)
+	require.Nil(t, chatReq.Tools[1].Function.Strict)
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	var tools []map[string]any
+	require.NoError(t, common.Unmarshal(back.Tools, &tools))
+	require.Len(t, tools, 2)
+	require.Equal(t, true, tools[0]["strict"])
+	require.NotContains(t, tools[1], "strict")
+}
+
+func TestServiceTierRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:       "gpt-4.1",
+		Input:       []byte(`"hi"`),
+		ServiceTier: "flex",
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.JSONEq(t, `"flex"`, string(chatReq.ServiceTier))
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.Equal(t, "flex", back.ServiceTier)
+
+	chatResp := &dto.OpenAITextResponse{
+		Model:       "gpt-4.1",
+		ServiceTier: "default",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Index: 0, Message: dto.Message{Role: "assistant", Content: "hello"}, FinishReason: "stop"},
+		},
+	}
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Equal(t, "default", responsesResp.ServiceTier)
+
+	backResp, _, err := ResponsesResponseToChatCompletionsResponse(responsesResp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "default", backResp.ServiceTier)
+}
+
+func TestAudioOutputRoundTrip(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{
+		Model: "gpt-4o-audio-preview",
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index: 0,
+				Message: dto.Message{
+					Role:  "assistant",
+					Audio: &dto.MessageAudio{Id: "audio_1", Data: "UklGRg==", Transcript: "Hello there"},
+				},
+				FinishReason: "stop",
+			},
+		},
+	}
+
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 1)
+	require.Len(t, responsesResp.Output[0].Content, 1)
+	part := responsesResp.Output[0].Content[0]
+	require.Equal(t, "output_audio", part.Type)
+	require.Equal(t, "UklGRg==", part.Data)
+	require.Equal(t, "Hello there", part.Transcript)
+	require.Equal(t, "Hello there", ExtractOutputTextFromResponses(responsesResp))
+
+	backResp, _, err := ResponsesResponseToChatCompletionsResponse(responsesResp, "chatcmpl-1")
+	require.NoError(t, err)
+	msg := backResp.Choices[0].Message
+	require.NotNil(t, msg.Audio)
+	require.Equal(t, "UklGRg==", msg.Audio.Data)
+	require.Equal(t, "Hello there", msg.Audio.Transcript)
+	require.Empty(t, msg.StringContent())
+}
+
+func TestMessageNameRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`[
+			{"role":"developer","name":"reviewer","content":"be strict"},
+			{"role":"user","name":"alice","content":"hi"},
+			{"role":"assistant","content":"hello"}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 3)
+	require.Equal(t, "system", chatReq.Messages[0].Role)
+	require.Equal(t, "reviewer", *chatReq.Messages[0].Name)
+	require.Equal(t, "alice", *chatReq.Messages[1].Name)
+	require.Nil(t, chatReq.Messages[2].Name)
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.Empty(t, back.Instructions)
+	var items []map[string]any
+	require.NoError(t, common.Unmarshal(back.Input, &items))
+	require.Len(t, items, 3)
+	require.Equal(t, "system", items[0]["role"])
+	require.Equal(t, "reviewer", items[0]["name"])
+	require.Equal(t, "alice", items[1]["name"])
+	require.NotContains(t, items[2], "name")
+}
+
+func TestChatResponseWithDeltaChoices(t *testing.T) {
+	var chatResp dto.OpenAITextResponse
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4.1",
+		"choices":[{"index":0,"delta":{"role":"assistant","content":"hello",
+			"tool_calls":[{"id":"call_1","type":"function","function":{"name":"ping","arguments":"{}"}}]},
+			"finish_reason":"tool_calls"}]
+	}`), &chatResp))
+
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(&chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 2)
+	require.Equal(t, "hello", responsesResp.Output[0].Content[0].Text)
+	require.Equal(t, "function_call", responsesResp.Output[1].Type)
+	require.Equal(t, "call_1", responsesResp.Output[1].CallId)
+}
+
+func TestTruncationRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:      "gpt-4.1",
+		Input:      []byte(`"hi"`),
+		Truncation: []byte(`"auto"`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.JSONEq(t, `"auto"`, string(chatReq.Truncation))
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.JSONEq(t, `"auto"`, string(back.Truncation))
+
+	require.JSONEq(t, `"auto"`, string(ResponsesTruncation(req.Truncation)))
+	require.JSONEq(t, `"disabled"`, string(ResponsesTruncation(nil)))
+
+	state := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	state.Truncation = req.Truncation
+	content := "hello"
+	events := state.HandleChatChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}}},
+	})
+	require.JSONEq(t, `"auto"`, string(events[0].Response.Truncation))
+	final := state.FinalEvents(nil)
+	require.JSONEq(t, `"auto"`, string(final[len(final)-1].Response.Truncation))
+}
+
+func TestTruncationDisabledIsNotForwarded(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:      "gpt-4.1",
+		Input:      []byte(`"hi"`),
+		Truncation: []byte(`"disabled"`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Empty(t, chatReq.Truncation)
+}
+
+func TestTextVerbosityRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-5",
+		Input: []byte(`"hi"`),
+		Text:  []byte(`{"format":{"type":"json_object"},"verbosity":"low"}`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.JSONEq(t, `"low"`, string(chatReq.Verbosity))
+	require.Equal(t, "json_object", chatReq.ResponseFormat.Type)
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"format":{"type":"json_object"},"verbosity":"low"}`, string(back.Text))
+
+	// Verbosity alone still produces a text object.
+	req.Text = []byte(`{"verbosity":"high"}`)
+	chatReq, err = ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Nil(t, chatReq.ResponseFormat)
+	back, err = ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"verbosity":"high"}`, string(back.Text))
+}
+
+func TestWhitespaceOnlyContentSkipsMessageItem(t *testing.T) {
+	toolCall := dto.Message{Role: "assistant", Content: " \n "}
+	toolCall.SetToolCalls([]dto.ToolCallRequest{{ID: "call_1", Type: "function", Function: dto.FunctionRequest{Name: "ping", Arguments: "{}"}}})
+	chatResp := &dto.OpenAITextResponse{
+		Model:   "gpt-4.1",
+		Choices: []dto.OpenAITextResponseChoice{{Index: 0, Message: toolCall, FinishReason: "tool_calls"}},
+	}
+
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 1)
+	require.Equal(t, "function_call", responsesResp.Output[0].Type)
+
+	// Annotations keep the message even without text.
+	chatResp.Choices[0].Message.Annotations = []dto.MessageAnnotation{
+		{Type: "file_citation", FileCitation: &dto.MessageFileCitation{FileId: "file_1", Filename: "a.pdf"}},
+	}
+	responsesResp, err = ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 2)
+	require.Equal(t, "message", responsesResp.Output[0].Type)
+	require.Equal(t, []interface{}{map[string]any{"type": "file_citation", "file_id": "file_1", "filename": "a.pdf"}}, responsesResp.Output[0].Content[0].Annotations)
+}
+
+func TestChatToolCallsOrderedByIndex(t *testing.T) {
+	var chatResp dto.OpenAITextResponse
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"model":"gpt-4.1",
+		"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[
+			{"index":2,"id":"call_c","type":"function","function":{"name":"c","arguments":"{}"}},
+			{"index":0,"id":"call_a","type":"function","function":{"name":"a","arguments":"{}"}},
+			{"index":1,"id":"call_b","type":"function","function":{"name":"b","arguments":"{}"}}
+		]}}]
+	}`), &chatResp))
+
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(&chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 3)
+	for i, callID := range []string{"call_a", "call_b", "call_c"} {
+		require.Equal(t, callID, responsesResp.Output[i].CallId)
+	}
+
+}
+
+func TestRequestHistoryToolCallsOrderedByIndex(t *testing.T) {
+	requestFunctionCallIDs := func(toolCalls string) []any {
+		var chatReq dto.GeneralOpenAIRequest
+		require.NoError(t, common.UnmarshalJsonStr(`{
+			"model":"gpt-4.1",
+			"messages":[
+				{"role":"user","content":"go"},
+				{"role":"assistant","content":null,"tool_calls":`+toolCalls+`}
+			]
+		}`, &chatReq))
+		responsesReq, err := ChatCompletionsRequestToResponsesRequest(&chatReq)
+		require.NoError(t, err)
+		var input []map[string]any
+		require.NoError(t, common.Unmarshal(responsesReq.Input, &input))
+		var callIDs []any
+		for _, item := range input {
+			if item["type"] == "function_call" {
+				callIDs = append(callIDs, item["call_id"])
+			}
+		}
+		return callIDs
+	}
+
+	// Replayed assistant tool calls follow their index, like responses do.
+	require.Equal(t, []any{"call_a", "call_b", "call_c"}, requestFunctionCallIDs(`[
+		{"index":2,"id":"call_c","type":"function","function":{"name":"c","arguments":"{}"}},
+		{"index":0,"id":"call_a","type":"function","function":{"name":"a","arguments":"{}"}},
+		{"index":1,"id":"call_b","type":"function","function":{"name":"b","arguments":"{}"}}
+	]`))
+
+	// Without indexes the request order is kept.
+	require.Equal(t, []any{"call_c", "call_a"}, requestFunctionCallIDs(`[
+		{"id":"call_c","type":"function","function":{"name":"c","arguments":"{}"}},
+		{"id":"call_a","type":"function","function":{"name":"a","arguments":"{}"}}
+	]`))
+}
+
+func TestInputAudioRequestRoundTrip(t *testing.T) {
+	var chatReq dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "gpt-4o-audio-preview",
+		"messages": [{"role":"user","content":[
+			{"type":"text","text":"What is said here?"},
+			{"type":"input_audio","input_audio":{"data":"UklGRg==","format":"wav"}}
+		]}]
+	}`, &chatReq))
+
+	responsesReq, err := ChatCompletionsRequestToResponsesRequest(&chatReq)
+	require.NoError(t, err)
+	var input []map[string]any
+	require.NoError(t, common.Unmarshal(responsesReq.Input, &input))
+	require.Len(t, input, 1)
+	content := input[0]["content"].([]any)
+	require.Equal(t, map[string]any{"type": "input_audio", "data": "UklGRg==", "format": "wav"}, content[1])
+
+	back, err := ResponsesRequestToChatCompletionsRequest(responsesReq)
+	require.NoError(t, err)
+	raw, err := common.Marshal(back.Messages[0])
+	require.NoError(t, err)
+	var msg dto.Message
+	require.NoError(t, common.Unmarshal(raw, &msg))
+	parts := msg.ParseContent()
+	require.Len(t, parts, 2)
+	require.Equal(t, "What is said here?", parts[0].Text)
+	require.Equal(t, &dto.MessageInputAudio{Data: "UklGRg==", Format: "wav"}, parts[1].GetInputAudio())
+}
+
+func TestChatResponseRefusalLogprobs(t *testing.T) {
+	var resp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id": "chatcmpl-1",
+		"model": "gpt-4.1",
+		"choices": [{
+			"index": 0,
+			"message": {"role": "assistant", "content": null, "refusal": "I can't help with that."},
+			"logprobs": {
+				"content": null,
+				"refusal": [
+					{"token": "I", "logprob": -0.01, "bytes": [73], "top_logprobs": []},
+					{"token": " can't", "logprob": -0.2, "bytes": [32, 99, 97, 110, 39, 116], "top_logprobs": []}
+				]
+			},
+			"finish_reason": "stop"
+		}],
+		"usage": {"prompt_tokens": 5, "completion_tokens": 6, "total_tokens": 11}
+	}`, &resp))
+
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(&resp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 1)
+	content := responsesResp.Output[0].Content
+	require.Len(t, content, 1)
+	require.Equal(t, "refusal", content[0].Type)
+	require.Equal(t, "I can't help with that.", content[0].Refusal)
+	require.JSONEq(t, `[
+		{"token": "I", "logprob": -0.01, "bytes": [73], "top_logprobs": []},
+		{"token": " can't", "logprob": -0.2, "bytes": [32, 99, 97, 110, 39, 116], "top_logprobs": []}
+	]`, string(content[0].Logprobs))
+}
+
+func TestChatResponseRequestedAndServedModel(t *testing.T) {
+	var resp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id":"chatcmpl-1","model":"gpt-4.1-2025-04-14",
+		"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]
+	}`, &resp))
+
+	out, err := ChatCompletionsResponseToResponsesResponse(&resp, "")
+	require.NoError(t, err)
+	require.Equal(t, "gpt-4.1-2025-04-14", out.Model)
+	require.Nil(t, out.Metadata)
+
+	out, err = ChatCompletionsResponseToResponsesResponseWithOptions(&resp, "", ChatResponseToResponsesOptions{RequestedModel: "my-alias"})
+	require.NoError(t, err)
+	require.Equal(t, "my-alias", out.Model)
+	require.Nil(t, out.Metadata)
+
+	out, err = ChatCompletionsResponseToResponsesResponseWithOptions(&resp, "", ChatResponseToResponsesOptions{RequestedModel: "my-alias", ExposeServedModel: true})
+	require.NoError(t, err)
+	require.Equal(t, "my-alias", out.Model)
+	require.JSONEq(t, `{"served_model":"gpt-4.1-2025-04-14"}`, string(out.Metadata))
+
+	out, err = ChatCompletionsResponseToResponsesResponseWithOptions(&resp, "gpt-4.1-2025-04-14", ChatResponseToResponsesOptions{RequestedModel: "gpt-4.1-2025-04-14", ExposeServedModel: true})
+	require.NoError(t, err)
+	require.Nil(t, out.Metadata, "nothing to expose when the models match")
+}
+
+func TestChatResponseToolCallWithoutID(t *testing.T) {
+	var resp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id":"chatcmpl-1","model":"gpt-4.1",
+		"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+			{"function":{"name":"lookup","arguments":"{\"q\":1}"}},
+			{"id":"call_2","type":"function","function":{"name":"other","arguments":"{}"}}
+		]},"finish_reason":"tool_calls"}]
+	}`, &resp))
+
+	out, err := ChatCompletionsResponseToResponsesResponse(&resp, "")
+	require.NoError(t, err)
+	require.Len(t, out.Output, 2)
+	call := out.Output[0]
+	require.Equal(t, "function_call", call.Type)
+	require.Equal(t, "lookup", call.Name)
+	require.True(t, strings.HasPrefix(call.CallId, "call_"))
+	require.Len(t, call.CallId, len("call_")+24)
+	require.Equal(t, "call_2", out.Output[1].CallId)
+
+	// The generated ID correlates the client's function_call_output.
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(out, "chatcmpl-2")
+	require.NoError(t, err)
+	require.Equal(t, call.CallId, chatResp.Choices[0].Message.ParseToolCalls()[0].ID)
+}
+
+func TestInputFileRequestRoundTrip(t *testing.T) {
+	var chatReq dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "gpt-4.1",
+		"messages": [{"role":"user","content":[
+			{"type":"file","file":{"filename":"report.pdf","file_data":"data:application/pdf;base64,JVBERi0="}},
+			{"type":"file","file":{"file_id":"file-abc"}}
+		]}]
+	}`, &chatReq))
+
+	responsesReq, err := ChatCompletionsRequestToResponsesRequest(&chatReq)
+	require.NoError(t, err)
+	var input []map[string]any
+	require.NoError(t, common.Unmarshal(responsesReq.Input, &input))
+	content := input[0]["content"].([]any)
+	require.Equal(t, map[string]any{"type": "input_file", "filename": "report.pdf", "file_data": "data:application/pdf;base64,JVBERi0="}, content[0])
+	require.Equal(t, map[string]any{"type": "input_file", "file_id": "file-abc"}, content[1])
+
+	back, err := ResponsesRequestToChatCompletionsRequest(responsesReq)
+	require.NoError(t, err)
+	raw, err := common.Marshal(back.Messages[0])
+	require.NoError(t, err)
+	require.Contains(t, string(raw), `"file":{"filename":"report.pdf","file_data":"data:application/pdf;base64,JVBERi0="}`)
+	var msg dto.Message
+	require.NoError(t, common.Unmarshal(raw, &msg))
+	parts := msg.ParseContent()
+	require.Len(t, parts, 2)
+	require.Equal(t, &dto.MessageFile{FileName: "report.pdf", FileData: "data:application/pdf;base64,JVBERi0="}, parts[0].GetFile())
+	require.Equal(t, &dto.MessageFile{FileId: "file-abc"}, parts[1].GetFile())
+
+	_, err = ResponsesRequestToChatCompletionsRequest(&dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[{"role":"user","content":[{"type":"input_file","filename":"empty.pdf"}]}]`),
+	})
+	require.Error(t, err)
+}
+
+func TestInputFileURL(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[{"role":"user","content":[
+			{"type":"input_file","file_url":"https://example.com/report.pdf"},
+			{"type":"input_file","file_url":{"url":"https://example.com/notes.pdf"}}
+		]}]`),
+	}
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	raw, err := common.Marshal(chatReq.Messages[0])
+	require.NoError(t, err)
+	var msg dto.Message
+	require.NoError(t, common.Unmarshal(raw, &msg))
+	parts := msg.ParseContent()
+	require.Len(t, parts, 2)
+	require.Equal(t, &dto.MessageFile{FileUrl: "https://example.com/report.pdf"}, parts[0].GetFile())
+	require.Equal(t, &dto.MessageFile{FileUrl: "https://example.com/notes.pdf"}, parts[1].GetFile())
+
+	var decoded dto.Message
+	require.NoError(t, common.Unmarshal(raw, &decoded))
+	chatReq.Messages[0] = decoded
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	var input []map[string]any
+	require.NoError(t, common.Unmarshal(back.Input, &input))
+	content := input[0]["content"].([]any)
+	require.Equal(t, map[string]any{"type": "input_file", "file_url": "https://example.com/report.pdf"}, content[0])
+}
+
+func TestToolChoiceRoundTrip(t *testing.T) {
+	cases := []struct {
+		chat      string
+		responses string
+	}{
+		{`"required"`, `"required"`},
+		{`{"type":"function","function":{"name":"get_weather"}}`, `{"name":"get_weather","type":"function"}`},
+		{`{"type":"custom","custom":{"name":"run_sql"}}`, `{"name":"run_sql","type":"custom"}`},
+		{
+			`{"type":"allowed_tools","allowed_tools":{"mode":"auto","tools":[{"type":"function","function":{"name":"get_weather"}}]}}`,
+			`{"mode":"auto","tools":[{"name":"get_weather","type":"function"}],"type":"allowed_tools"}`,
+		},
+	}
+	for _, tc := range cases {
+		var chatReq dto.GeneralOpenAIRequest
+		require.NoError(t, common.UnmarshalJsonStr(`{
+			"model": "gpt-4.1",
+			"messages": [{"role":"user","content":"hi"}],
+			"tools": [
+				{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}},
+				{"type":"custom","custom":{"name":"run_sql"}}
+			],
+			"tool_choice": `+tc.chat+`
+		}`, &chatReq))
+
+		responsesReq, err := ChatCompletionsRequestToResponsesRequest(&chatReq)
+		require.NoError(t, err)
+		require.JSONEq(t, tc.responses, string(responsesReq.ToolChoice))
+
+		back, err := ResponsesRequestToChatCompletionsRequest(responsesReq)
+		require.NoError(t, err)
+		raw, err := common.Marshal(back.ToolChoice)
+		require.NoError(t, err)
+		require.JSONEq(t, tc.chat, string(raw))
+	}
+}
+
+func TestChatResponseUsageOverride(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{
+		Model: "gpt-4.1",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "hi"}, FinishReason: "stop"},
+		},
+		Usage: dto.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+	}
+
+	resp, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Equal(t, 10, resp.Usage.InputTokens)
+	require.Equal(t, 12, resp.Usage.TotalTokens)
+
+	override := &dto.Usage{
+		PromptTokens:           25,
+		CompletionTokens:       6,
+		PromptTokensDetails:    dto.InputTokenDetails{CachedTokens: 8},
+		CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: 4},
+	}
+	resp, err = ChatCompletionsResponseToResponsesResponseWithOptions(chatResp, "", ChatResponseToResponsesOptions{Usage: override})
+	require.NoError(t, err)
+	require.Equal(t, 25, resp.Usage.InputTokens)
+	require.Equal(t, 6, resp.Usage.OutputTokens)
+	require.Equal(t, 31, resp.Usage.TotalTokens)
+	require.Equal(t, 8, resp.Usage.InputTokensDetails.CachedTokens)
+	require.Equal(t, 4, resp.Usage.OutputTokensDetails.ReasoningTokens)
+
+	// The override may use the Responses field names as well.
+	override = &dto.Usage{
+		InputTokens:         25,
+		OutputTokens:        6,
+		InputTokensDetails:  &dto.InputTokenDetails{CachedTokens: 8},
+		OutputTokensDetails: &dto.OutputTokenDetails{ReasoningTokens: 4},
+	}
+	resp, err = ChatCompletionsResponseToResponsesResponseWithOptions(chatResp, "", ChatResponseToResponsesOptions{Usage: override})
+	require.NoError(t, err)
+	require.Equal(t, 25, resp.Usage.PromptTokens)
+	require.Equal(t, 6, resp.Usage.CompletionTokens)
+	require.Equal(t, 31, resp.Usage.TotalTokens)
+	require.Equal(t, 8, resp.Usage.PromptTokensDetails.CachedTokens)
+	require.Equal(t, 4, resp.Usage.CompletionTokenDetails.ReasoningTokens)
+	require.Equal(t, 10, chatResp.Usage.PromptTokens)
+}
+
+func TestChatResponseNullContentWithToolCallAndReasoning(t *testing.T) {
+	var chatResp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id": "chatcmpl-1",
+		"model": "deepseek-reasoner",
+		"choices": [{
+			"index": 0,
+			"finish_reason": "tool_calls",
+			"message": {
+				"role": "assistant",
+				"content": null,
+				"reasoning_content": "I need the current weather first.",
+				"tool_calls": [{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]
+			}
+		}]
+	}`, &chatResp))
+
+	resp, err := ChatCompletionsResponseToResponsesResponse(&chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, resp.Output, 2)
+	require.Equal(t, "reasoning", resp.Output[0].Type)
+	require.Equal(t, []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: "I need the current weather first."}}, resp.Output[0].Summary)
+	require.Empty(t, resp.Output[0].EncryptedContent)
+	require.Equal(t, "function_call", resp.Output[1].Type)
+	require.Equal(t, "call_1", resp.Output[1].CallId)
+	require.JSONEq(t, `{"city":"Paris"}`, string(resp.Output[1].Arguments))
+}
@@ -0,0 +1,77 @@
+package openaicompat
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// IDPrefixes are the prefixes of the IDs the conversions generate. Gateways
+// can namespace them to tell which one minted an ID; empty fields fall back
+// to DefaultIDPrefixes.
+type IDPrefixes struct {
+	Response       string
+	Message        string
+	FunctionCall   string
+	CustomToolCall string
+	Reasoning      string
+	// ToolCall prefixes call IDs made up for tool calls streamed without one.
+	ToolCall string
+}
+
+// DefaultIDPrefixes are the prefixes used by the OpenAI Responses API.
+var DefaultIDPrefixes = IDPrefixes{
+	Response:       "resp_",
+	Message:        "msg_",
+	FunctionCall:   "fc_",
+	CustomToolCall: "ctc_",
+	Reasoning:      "rs_",
+	ToolCall:       "call_",
+}
+
+func (p IDPrefixes) withDefaults() IDPrefixes {
+	if p.Response == "" {
+		p.Response = DefaultIDPrefixes.Response
+	}
+	if p.Message == "" {
+		p.Message = DefaultIDPrefixes.Message
+	}
+	if p.FunctionCall == "" {
+		p.FunctionCall = DefaultIDPrefixes.FunctionCall
+	}
+	if p.CustomToolCall == "" {
+		p.CustomToolCall = DefaultIDPrefixes.CustomToolCall
+	}
+	if p.Reasoning == "" {
+		p.Reasoning = DefaultIDPrefixes.Reasoning
+	}
+	if p.ToolCall == "" {
+		p.ToolCall = DefaultIDPrefixes.ToolCall
+	}
+	return p
+}
+
+// normalizeResponsesID turns an upstream Chat completion ID into a response
+// ID carrying responsePrefix, keeping IDs that already have it.
+func normalizeResponsesID(id string, responsePrefix string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return responsePrefix + common.GetUUID()
+	}
+	if strings.HasPrefix(id, responsePrefix) {
+		return id
+	}
+	if strings.HasPrefix(id, "chatcmpl-") {
+		return responsePrefix + strings.TrimPrefix(id, "chatcmpl-")
+	}
+	if strings.HasPrefix(id, "chatcmpl_") {
+		return responsePrefix + strings.TrimPrefix(id, "chatcmpl_")
+	}
+	return responsePrefix + id
+}
+
+// syntheticCallID makes up a call ID for a tool call sent without one. The
+// stream and non-stream conversions share it so the IDs look alike.
+func (p IDPrefixes) syntheticCallID() string {
+	return p.ToolCall + common.GetUUID()[:24]
+}
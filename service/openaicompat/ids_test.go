@@ -0,0 +1,73 @@
+package openaicompat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeResponsesIDWithPrefix(t *testing.T) {
+	require.Equal(t, "resp_abc", normalizeResponsesID("chatcmpl-abc", "resp_"))
+	require.Equal(t, "gw1resp_abc", normalizeResponsesID("chatcmpl-abc", "gw1resp_"))
+	require.Equal(t, "gw1resp_abc", normalizeResponsesID("gw1resp_abc", "gw1resp_"))
+	require.True(t, strings.HasPrefix(normalizeResponsesID("", "gw1resp_"), "gw1resp_"))
+}
+
+func TestCustomIDPrefixes(t *testing.T) {
+	prefixes := IDPrefixes{Response: "gw1resp_", Message: "gw1msg_", FunctionCall: "gw1fc_", Reasoning: "gw1rs_"}
+
+	state := NewChatToResponsesStreamStateWithOptions("chatcmpl-abc", 1700000000, "gpt-4.1", ChatToResponsesStreamOptions{IDPrefixes: prefixes})
+	for _, raw := range []string{
+		`{"choices":[{"index":0,"delta":{"reasoning_content":"hmm"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"f","arguments":"{}"}}]}}]}`,
+	} {
+		var chunk dto.ChatCompletionsStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &chunk))
+		state.HandleChatChunk(&chunk)
+	}
+	require.Equal(t, "gw1resp_abc", state.ResponseID)
+	require.Equal(t, "gw1rs_abc", state.ReasoningItemID)
+	require.True(t, strings.HasPrefix(state.MessageItemID, "gw1msg_"))
+	require.True(t, strings.HasPrefix(state.ToolCallOrder[0], "call_"), "unset prefixes keep their defaults")
+
+	var resp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"choices":[{"index":0,"message":{"role":"assistant","content":"hi","tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}]
+	}`, &resp))
+	out, err := ChatCompletionsResponseToResponsesResponseWithOptions(&resp, "gpt-4.1", ChatResponseToResponsesOptions{IDPrefixes: prefixes})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out.ID, "gw1resp_"))
+	require.True(t, strings.HasPrefix(out.Output[0].ID, "gw1msg_"))
+	require.True(t, strings.HasPrefix(out.Output[1].ID, "gw1fc_"))
+
+	out, err = ChatCompletionsResponseToResponsesResponse(&resp, "gpt-4.1")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out.ID, "resp_"))
+	require.True(t, strings.HasPrefix(out.Output[1].ID, "fc_"))
+}
+
+func TestSyntheticCallIDsMatchAcrossStreamAndNonStream(t *testing.T) {
+	var resp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model":"gpt-4.1",
+		"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"type":"function","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}]
+	}`, &resp))
+	out, err := ChatCompletionsResponseToResponsesResponse(&resp, "gpt-4.1")
+	require.NoError(t, err)
+	nonStreamID := out.Output[0].CallId
+
+	state := NewChatToResponsesStreamState("chatcmpl-abc", 1700000000, "gpt-4.1")
+	var chunk dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"f","arguments":"{}"}}]}}]}`, &chunk))
+	state.HandleChatChunk(&chunk)
+	streamID := state.ToolCallOrder[0]
+
+	for _, id := range []string{nonStreamID, streamID} {
+		require.True(t, strings.HasPrefix(id, "call_"))
+		require.Len(t, id, len("call_")+24)
+	}
+}
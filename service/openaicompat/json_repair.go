@@ -0,0 +1,81 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// partialJSONLiteralChars are the characters a number or a true/false/null
+// literal cut off mid-token can end with.
+const partialJSONLiteralChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.+-"
+
+// repairTruncatedJSON closes JSON that was cut off: an open string is
+// terminated, a dangling key, colon, comma or partial literal is completed or
+// removed, and open objects and arrays are closed. It reports false when the
+// result is still not valid JSON.
+func repairTruncatedJSON(s string) (string, bool) {
+	if strings.TrimSpace(s) == "" || json.Valid([]byte(s)) {
+		return s, json.Valid([]byte(s))
+	}
+
+	var stack []byte
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, c)
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	var closers strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			closers.WriteByte('}')
+		} else {
+			closers.WriteByte(']')
+		}
+	}
+
+	bases := []string{s}
+	if inString {
+		base := s
+		if escaped {
+			base = base[:len(base)-1]
+		}
+		// Drop a unicode escape that was cut short.
+		if i := strings.LastIndex(base, `\u`); i >= 0 && len(base)-i < 6 {
+			base = base[:i]
+		}
+		bases = []string{base + `"`}
+	} else {
+		bases = append(bases, strings.TrimRight(s, partialJSONLiteralChars))
+	}
+
+	for _, base := range bases {
+		base = strings.TrimRight(base, " \t\r\n")
+		for _, candidate := range []string{base, strings.TrimSuffix(base, ","), base + "null", base + ":null"} {
+			if repaired := candidate + closers.String(); json.Valid([]byte(repaired)) {
+				return repaired, true
+			}
+		}
+	}
+	return s, false
+}
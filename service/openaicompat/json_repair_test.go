@@ -0,0 +1,32 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairTruncatedJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"city":"Paris"}`:            `{"city":"Paris"}`,
+		`{"city":"Par`:                `{"city":"Par"}`,
+		`{"city":"Paris",`:            `{"city":"Paris"}`,
+		`{"city":`:                    `{"city":null}`,
+		`{"city"`:                     `{"city":null}`,
+		`{"n": 12`:                    `{"n": 12}`,
+		`{"ok": tru`:                  `{"ok":null}`,
+		`{"tags":["a","b`:             `{"tags":["a","b"]}`,
+		`{"q":{"text":"line\`:         `{"q":{"text":"line"}}`,
+		`{"s":"caf\u00`:               `{"s":"caf"}`,
+		`[{"a":1},{"b":[1,2`:          `[{"a":1},{"b":[1,2]}]`,
+		`{"nested":{"deep":{"x":"y"}`: `{"nested":{"deep":{"x":"y"}}}`,
+	}
+	for input, expected := range cases {
+		repaired, ok := repairTruncatedJSON(input)
+		require.True(t, ok, input)
+		require.Equal(t, expected, repaired, input)
+	}
+
+	_, ok := repairTruncatedJSON(`{"a":1}}`)
+	require.False(t, ok)
+}
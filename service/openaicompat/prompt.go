@@ -0,0 +1,153 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+)
+
+// ResponsesPrompt is the stored prompt reference of a Responses request.
+type ResponsesPrompt struct {
+	Id        string         `json:"id"`
+	Version   string         `json:"version,omitempty"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// ResolvedPrompt is the content of a stored prompt. Input uses the Responses
+// input shape (a string or an array of items); both fields may contain
+// {{variable}} placeholders.
+type ResolvedPrompt struct {
+	Instructions string
+	Input        json.RawMessage
+}
+
+// PromptResolver loads a stored prompt by id and optional version.
+type PromptResolver func(id string, version string) (*ResolvedPrompt, error)
+
+// expandResponsesPrompt returns a copy of req with its stored prompt expanded
+// into instructions and input: the prompt's instructions and input come
+// first, followed by the request's own. Requests without a prompt are
+// returned unchanged.
+func expandResponsesPrompt(req *dto.OpenAIResponsesRequest, resolver PromptResolver) (*dto.OpenAIResponsesRequest, error) {
+	if len(req.Prompt) == 0 || common.GetJsonType(req.Prompt) != "object" {
+		return req, nil
+	}
+	var prompt ResponsesPrompt
+	if err := common.Unmarshal(req.Prompt, &prompt); err != nil {
+		return nil, fmt.Errorf(i18n.Translate("svc.failed_to_parse_prompt"), err)
+	}
+	if prompt.Id == "" {
+		return req, nil
+	}
+	if resolver == nil {
+		return nil, fmt.Errorf(i18n.Translate("svc.prompt_resolver_unavailable"), prompt.Id)
+	}
+	resolved, err := resolver(prompt.Id, prompt.Version)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.Translate("svc.failed_to_resolve_prompt"), prompt.Id, err)
+	}
+	if resolved == nil {
+		return nil, fmt.Errorf(i18n.Translate("svc.prompt_not_found"), prompt.Id)
+	}
+
+	replacer := promptVariableReplacer(prompt.Variables)
+
+	out := *req
+	out.Prompt = nil
+
+	instructions := replacer.Replace(resolved.Instructions)
+	var own string
+	if len(req.Instructions) > 0 {
+		_ = common.Unmarshal(req.Instructions, &own)
+	}
+	if joined := joinNonEmpty("\n\n", instructions, own); joined != "" {
+		out.Instructions, _ = common.Marshal(joined)
+	}
+
+	promptItems, err := responsesInputItems(resolved.Input)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range promptItems {
+		promptItems[i] = substitutePromptVariables(item, replacer)
+	}
+	ownItems, err := responsesInputItems(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	if items := append(promptItems, ownItems...); len(items) > 0 {
+		if out.Input, err = common.Marshal(items); err != nil {
+			return nil, err
+		}
+	}
+	return &out, nil
+}
+
+// promptVariableReplacer substitutes {{name}} placeholders with the string
+// values of variables. Non-string values (e.g. input_image objects) are left
+// for upstreams that understand them and are not substituted.
+func promptVariableReplacer(variables map[string]any) *strings.Replacer {
+	var pairs []string
+	for name, value := range variables {
+		if s, ok := value.(string); ok {
+			pairs = append(pairs, "{{"+name+"}}", s)
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// substitutePromptVariables applies replacer to every string in a decoded
+// JSON value.
+func substitutePromptVariables(v any, replacer *strings.Replacer) any {
+	switch value := v.(type) {
+	case string:
+		return replacer.Replace(value)
+	case []any:
+		for i := range value {
+			value[i] = substitutePromptVariables(value[i], replacer)
+		}
+		return value
+	case map[string]any:
+		for k := range value {
+			value[k] = substitutePromptVariables(value[k], replacer)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// responsesInputItems decodes a Responses input into a list of items, turning
+// a plain string input into a single user message.
+func responsesInputItems(input json.RawMessage) ([]any, error) {
+	switch common.GetJsonType(input) {
+	case "string":
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, fmt.Errorf(i18n.Translate("svc.failed_to_parse_input"), err)
+		}
+		return []any{map[string]any{"role": "user", "content": text}}, nil
+	case "array":
+		var items []any
+		if err := common.Unmarshal(input, &items); err != nil {
+			return nil, fmt.Errorf(i18n.Translate("svc.failed_to_parse_input"), err)
+		}
+		return items, nil
+	default:
+		return nil, nil
+	}
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
@@ -0,0 +1,87 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesPromptExpansion(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:  "gpt-4.1",
+		Prompt: []byte(`{"id":"pmpt_123","version":"2","variables":{"city":"Paris","tone":"friendly"}}`),
+	}
+
+	var gotID, gotVersion string
+	resolver := func(id string, version string) (*ResolvedPrompt, error) {
+		gotID, gotVersion = id, version
+		return &ResolvedPrompt{
+			Instructions: "You are a {{tone}} travel guide.",
+			Input:        []byte(`[{"role":"user","content":"Plan a day in {{city}}."}]`),
+		}, nil
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{PromptResolver: resolver})
+	require.NoError(t, err)
+	require.Equal(t, "pmpt_123", gotID)
+	require.Equal(t, "2", gotVersion)
+	require.Len(t, chatReq.Messages, 2)
+	require.Equal(t, "system", chatReq.Messages[0].Role)
+	require.Equal(t, "You are a friendly travel guide.", chatReq.Messages[0].StringContent())
+	require.Equal(t, "user", chatReq.Messages[1].Role)
+	require.Equal(t, "Plan a day in Paris.", chatReq.Messages[1].StringContent())
+	require.Empty(t, req.Instructions, "the original request must not be modified")
+}
+
+func TestResponsesPromptWithoutResolver(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:  "gpt-4.1",
+		Prompt: []byte(`{"id":"pmpt_123"}`),
+	}
+
+	_, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pmpt_123")
+}
+
+func TestResponsesPromptWithInlineInput(t *testing.T) {
+	resolver := func(id string, version string) (*ResolvedPrompt, error) {
+		return &ResolvedPrompt{
+			Input: []byte(`[{"role":"developer","content":"Answer in {{lang}}."},{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello!"}]`),
+		}, nil
+	}
+
+	t.Run("array input", func(t *testing.T) {
+		req := &dto.OpenAIResponsesRequest{
+			Model:  "gpt-4.1",
+			Prompt: []byte(`{"id":"pmpt_123","variables":{"lang":"French"}}`),
+			Input:  []byte(`[{"role":"user","content":"What is {{lang}}?"},{"role":"user","content":"Thanks"}]`),
+		}
+
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{PromptResolver: resolver})
+		require.NoError(t, err)
+		require.Len(t, chatReq.Messages, 5)
+		require.Equal(t, "system", chatReq.Messages[0].Role)
+		require.Equal(t, "Answer in French.", chatReq.Messages[0].StringContent())
+		require.Equal(t, "Hi", chatReq.Messages[1].StringContent())
+		require.Equal(t, "Hello!", chatReq.Messages[2].StringContent())
+		require.Equal(t, "What is {{lang}}?", chatReq.Messages[3].StringContent(), "inline input is not templated")
+		require.Equal(t, "Thanks", chatReq.Messages[4].StringContent())
+	})
+
+	t.Run("string input", func(t *testing.T) {
+		req := &dto.OpenAIResponsesRequest{
+			Model:  "gpt-4.1",
+			Prompt: []byte(`{"id":"pmpt_123","variables":{"lang":"French"}}`),
+			Input:  []byte(`"Translate: good morning"`),
+		}
+
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{PromptResolver: resolver})
+		require.NoError(t, err)
+		require.Len(t, chatReq.Messages, 4)
+		require.Equal(t, "system", chatReq.Messages[0].Role)
+		require.Equal(t, "user", chatReq.Messages[3].Role)
+		require.Equal(t, "Translate: good morning", chatReq.Messages[3].StringContent())
+	})
+}
@@ -0,0 +1,35 @@
+package openaicompat
+
+import "strings"
+
+// reasoningEffortNoneModelPrefixes lists model families that accept
+// reasoning effort "none" as a distinct setting. Older reasoning models
+// reject it, and upstreams that ignore it fall back to their default effort.
+var reasoningEffortNoneModelPrefixes = []string{
+	"gpt-5.",
+}
+
+// ReasoningEffortForBudget maps a numeric reasoning token budget onto the
+// nearest effort level.
+func ReasoningEffortForBudget(budget int) string {
+	switch {
+	case budget <= 2048:
+		return "low"
+	case budget <= 8192:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// ModelSupportsReasoningEffortNone reports whether the model treats reasoning
+// effort "none" as "do not reason" rather than an invalid value.
+func ModelSupportsReasoningEffortNone(model string) bool {
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, prefix := range reasoningEffortNoneModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+package openaicompat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// ResponsesToChatStreamState tracks state for converting a Responses API SSE
+// stream into chat completions stream chunks. It is the inverse of
+// ChatToResponsesStreamState: callers decode each upstream event into a
+// *dto.ResponsesStreamResponse, feed it to HandleResponsesEvent and forward
+// the returned chunks to the client.
+type ResponsesToChatStreamState struct {
+	ID          string
+	CreatedAt   int64
+	Model       string
+	ServiceTier string
+	// SystemFingerprint is the backend fingerprint reported on the upstream
+	// response, echoed on every chunk once known.
+	SystemFingerprint string
+	SentStart         bool
+	SentStop          bool
+
+	OutputText         strings.Builder
+	RefusalText        strings.Builder
+	SawToolCall        bool
+	ToolCallIndex      map[string]int
+	ToolCallName       map[string]string
+	ToolCallArgs       map[string]string
+	ToolCallNameSent   map[string]bool
+	ToolCallIDByItemID map[string]string
+	// ReasoningText is the reasoning summary text streamed so far, per
+	// reasoning item ID.
+	ReasoningText map[string]string
+	// sentReasoning and needsReasoningSeparator put a blank line between
+	// consecutive reasoning summary parts.
+	sentReasoning           bool
+	needsReasoningSeparator bool
+
+	Usage *dto.Usage
+	// Err is set once the upstream reports a failure; the stream is terminal
+	// afterwards and further events are ignored.
+	Err *types.OpenAIError
+}
+
+func NewResponsesToChatStreamState(id string, createdAt int64, model string) *ResponsesToChatStreamState {
+	return &ResponsesToChatStreamState{
+		ID:                 id,
+		CreatedAt:          createdAt,
+		Model:              model,
+		ToolCallIndex:      make(map[string]int),
+		ToolCallName:       make(map[string]string),
+		ToolCallArgs:       make(map[string]string),
+		ToolCallNameSent:   make(map[string]bool),
+		ToolCallIDByItemID: make(map[string]string),
+		ReasoningText:      make(map[string]string),
+	}
+}
+
+// HandleResponsesEvent converts one Responses API stream event into zero or
+// more chat completions chunks. A response.failed or error event returns the
+// upstream error details; the caller should surface it and stop streaming.
+func (s *ResponsesToChatStreamState) HandleResponsesEvent(event *dto.ResponsesStreamResponse) ([]dto.ChatCompletionsStreamResponse, *types.OpenAIError) {
+	if event == nil {
+		return nil, nil
+	}
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	switch event.Type {
+	case "response.created", "response.in_progress":
+		s.updateFromResponse(event.Response)
+		return nil, nil
+
+	case "response.output_text.delta":
+		if event.Delta == "" {
+			return nil, nil
+		}
+		s.OutputText.WriteString(event.Delta)
+		delta := event.Delta
+		return s.withStart(s.deltaChunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: &delta})), nil
+
+	case "response.content_part.added":
+		// A refusal part may already carry text; later text arrives as
+		// response.refusal.delta events.
+		if event.Part == nil || event.Part.Type != "refusal" || event.Part.Refusal == "" {
+			return nil, nil
+		}
+		return s.refusalChunks(event.Part.Refusal), nil
+
+	case "response.refusal.delta":
+		return s.refusalChunks(event.Delta), nil
+
+	case "response.reasoning_summary_text.delta":
+		if event.Delta == "" {
+			return nil, nil
+		}
+		s.ReasoningText[event.ItemID] += event.Delta
+		return s.reasoningChunks(event.Delta), nil
+
+	case "response.reasoning_summary_text.done":
+		if s.sentReasoning {
+			s.needsReasoningSeparator = true
+		}
+		return nil, nil
+
+	case "response.output_item.added", "response.output_item.done":
+		if event.Item != nil && event.Item.Type == "reasoning" {
+			if event.Type != "response.output_item.done" {
+				return nil, nil
+			}
+			return s.reasoningItemDoneChunks(event.Item), nil
+		}
+		if event.Item == nil || event.Item.Type != "function_call" {
+			return nil, nil
+		}
+		itemID := strings.TrimSpace(event.Item.ID)
+		callID := strings.TrimSpace(event.Item.CallId)
+		if callID == "" {
+			callID = itemID
+		}
+		if callID == "" {
+			return nil, nil
+		}
+		if itemID != "" {
+			s.ToolCallIDByItemID[itemID] = callID
+		}
+		if name := strings.TrimSpace(event.Item.Name); name != "" {
+			s.ToolCallName[callID] = name
+		}
+		// Items may repeat the full arguments; only forward the unseen suffix.
+		argsDelta := ""
+		if newArgs := event.Item.ArgumentsString(); newArgs != "" {
+			prevArgs := s.ToolCallArgs[callID]
+			if strings.HasPrefix(newArgs, prevArgs) {
+				argsDelta = newArgs[len(prevArgs):]
+			} else {
+				argsDelta = newArgs
+			}
+			s.ToolCallArgs[callID] = newArgs
+		}
+		return s.toolCallChunks(callID, argsDelta), nil
+
+	case "response.function_call_arguments.delta":
+		itemID := strings.TrimSpace(event.ItemID)
+		callID := s.ToolCallIDByItemID[itemID]
+		if callID == "" {
+			callID = itemID
+		}
+		if callID == "" {
+			return nil, nil
+		}
+		s.ToolCallArgs[callID] += event.Delta
+		return s.toolCallChunks(callID, event.Delta), nil
+
+	case "response.completed":
+		s.updateFromResponse(event.Response)
+		if event.Response != nil && event.Response.Usage != nil {
+			s.Usage = responsesUsageToChatUsage(event.Response.Usage)
+		}
+		if s.SentStop {
+			return nil, nil
+		}
+		s.SentStop = true
+		return s.withStart(s.finishChunk(s.finishReason(event.Response))), nil
+
+	case "response.failed", "response.error", "error":
+		s.Err = responsesStreamEventError(event)
+		return nil, s.Err
+	}
+
+	return nil, nil
+}
+
+// FinalChunks closes a stream that ended without response.completed. It
+// returns the start chunk if nothing was sent yet, followed by the finish
+// chunk, and nothing once the stream has been finished.
+func (s *ResponsesToChatStreamState) FinalChunks() []dto.ChatCompletionsStreamResponse {
+	if s.SentStop {
+		return nil
+	}
+	s.SentStop = true
+	return s.withStart(s.finishChunk(s.finishReason(nil)))
+}
+
+func (s *ResponsesToChatStreamState) finishReason(resp *dto.OpenAIResponsesResponse) string {
+	if resp != nil && resp.GetStatus() == "requires_action" {
+		return "tool_calls"
+	}
+	if s.SawToolCall && s.OutputText.Len() == 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func (s *ResponsesToChatStreamState) updateFromResponse(resp *dto.OpenAIResponsesResponse) {
+	if resp == nil {
+		return
+	}
+	if resp.Model != "" {
+		s.Model = resp.Model
+	}
+	if resp.CreatedAt != 0 {
+		s.CreatedAt = int64(resp.CreatedAt)
+	}
+	if resp.ServiceTier != "" {
+		s.ServiceTier = resp.ServiceTier
+	}
+	if resp.SystemFingerprint != "" {
+		s.SystemFingerprint = resp.SystemFingerprint
+	}
+}
+
+// withStart prepends the assistant role chunk the first time content is sent.
+func (s *ResponsesToChatStreamState) withStart(chunks ...dto.ChatCompletionsStreamResponse) []dto.ChatCompletionsStreamResponse {
+	if s.SentStart {
+		return chunks
+	}
+	s.SentStart = true
+	start := s.deltaChunk(dto.ChatCompletionsStreamResponseChoiceDelta{
+		Role:    "assistant",
+		Content: common.GetPointer(""),
+	})
+	return append([]dto.ChatCompletionsStreamResponse{start}, chunks...)
+}
+
+func (s *ResponsesToChatStreamState) reasoningChunks(reasoning string) []dto.ChatCompletionsStreamResponse {
+	if reasoning == "" {
+		return nil
+	}
+	if s.needsReasoningSeparator {
+		s.needsReasoningSeparator = false
+		if !strings.HasPrefix(reasoning, "\n\n") {
+			if strings.HasPrefix(reasoning, "\n") {
+				reasoning = "\n" + reasoning
+			} else {
+				reasoning = "\n\n" + reasoning
+			}
+		}
+	}
+	s.sentReasoning = true
+	return s.withStart(s.deltaChunk(dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: &reasoning}))
+}
+
+// reasoningItemDoneChunks flushes the part of a finished reasoning item's
+// summary that was not streamed as deltas. A summary that does not extend
+// the streamed text is sent whole.
+func (s *ResponsesToChatStreamState) reasoningItemDoneChunks(item *dto.ResponsesOutput) []dto.ChatCompletionsStreamResponse {
+	var summary strings.Builder
+	for _, part := range item.Summary {
+		summary.WriteString(part.Text)
+	}
+	text := summary.String()
+	streamed := s.ReasoningText[item.ID]
+	s.ReasoningText[item.ID] = text
+	var chunks []dto.ChatCompletionsStreamResponse
+	if streamed != "" && strings.HasPrefix(text, streamed) {
+		// The suffix continues the streamed part, so no separator.
+		s.needsReasoningSeparator = false
+		chunks = s.reasoningChunks(text[len(streamed):])
+	} else {
+		chunks = s.reasoningChunks(text)
+	}
+	s.needsReasoningSeparator = s.sentReasoning
+	return chunks
+}
+
+func (s *ResponsesToChatStreamState) refusalChunks(refusal string) []dto.ChatCompletionsStreamResponse {
+	if refusal == "" {
+		return nil
+	}
+	s.RefusalText.WriteString(refusal)
+	return s.withStart(s.deltaChunk(dto.ChatCompletionsStreamResponseChoiceDelta{Refusal: &refusal}))
+}
+
+func (s *ResponsesToChatStreamState) toolCallChunks(callID string, argsDelta string) []dto.ChatCompletionsStreamResponse {
+	if s.OutputText.Len() > 0 {
+		// Prefer streaming assistant text over tool calls to match non-stream behavior.
+		return nil
+	}
+	idx, ok := s.ToolCallIndex[callID]
+	if !ok {
+		idx = len(s.ToolCallIndex)
+		s.ToolCallIndex[callID] = idx
+	}
+	tool := dto.ToolCallResponse{
+		ID:   callID,
+		Type: "function",
+		Function: dto.FunctionResponse{
+			Arguments: argsDelta,
+		},
+	}
+	tool.SetIndex(idx)
+	if name := s.ToolCallName[callID]; name != "" && !s.ToolCallNameSent[callID] {
+		tool.Function.Name = name
+		s.ToolCallNameSent[callID] = true
+	}
+	s.SawToolCall = true
+	return s.withStart(s.deltaChunk(dto.ChatCompletionsStreamResponseChoiceDelta{
+		ToolCalls: []dto.ToolCallResponse{tool},
+	}))
+}
+
+func (s *ResponsesToChatStreamState) deltaChunk(delta dto.ChatCompletionsStreamResponseChoiceDelta) dto.ChatCompletionsStreamResponse {
+	chunk := dto.ChatCompletionsStreamResponse{
+		Id:          s.ID,
+		Object:      "chat.completion.chunk",
+		Created:     s.CreatedAt,
+		Model:       s.Model,
+		ServiceTier: s.ServiceTier,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{
+			{
+				Index: 0,
+				Delta: delta,
+			},
+		},
+	}
+	if s.SystemFingerprint != "" {
+		chunk.SetSystemFingerprint(s.SystemFingerprint)
+	}
+	return chunk
+}
+
+func (s *ResponsesToChatStreamState) finishChunk(finishReason string) dto.ChatCompletionsStreamResponse {
+	chunk := s.deltaChunk(dto.ChatCompletionsStreamResponseChoiceDelta{})
+	chunk.Choices[0].FinishReason = &finishReason
+	return chunk
+}
+
+// responsesStreamEventError extracts the error carried by a response.failed
+// (nested under response.error) or a top-level error event.
+func responsesStreamEventError(event *dto.ResponsesStreamResponse) *types.OpenAIError {
+	if event.Response != nil {
+		if oaiErr := event.Response.GetOpenAIError(); oaiErr != nil && (oaiErr.Message != "" || oaiErr.Type != "") {
+			return oaiErr
+		}
+	}
+	if event.Message != "" {
+		return &types.OpenAIError{
+			Message: event.Message,
+			Type:    string(types.ErrorTypeUpstreamError),
+			Param:   event.Param,
+			Code:    event.Code,
+		}
+	}
+	return &types.OpenAIError{
+		Message: fmt.Sprintf(i18n.Translate("svc.responses_stream_failed"), event.Type),
+		Type:    string(types.ErrorTypeUpstreamError),
+	}
+}
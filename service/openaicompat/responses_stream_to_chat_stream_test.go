@@ -0,0 +1,157 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesToChatStreamFailedEvent(t *testing.T) {
+	state := NewResponsesToChatStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	chunks, oaiErr := state.HandleResponsesEvent(&dto.ResponsesStreamResponse{
+		Type:  "response.output_text.delta",
+		Delta: "partial",
+	})
+	require.Nil(t, oaiErr)
+	require.Len(t, chunks, 2)
+	require.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	require.Equal(t, "partial", chunks[1].Choices[0].Delta.GetContentString())
+
+	var failed dto.ResponsesStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"type":"response.failed",
+		"response":{
+			"id":"resp_1",
+			"status":"failed",
+			"error":{"code":"server_error","message":"The model crashed"}
+		}
+	}`, &failed))
+
+	chunks, oaiErr = state.HandleResponsesEvent(&failed)
+	require.Empty(t, chunks)
+	require.NotNil(t, oaiErr)
+	require.Equal(t, "The model crashed", oaiErr.Message)
+	require.Equal(t, "server_error", oaiErr.Code)
+
+	// The stream is terminal once it has failed.
+	chunks, oaiErr = state.HandleResponsesEvent(&dto.ResponsesStreamResponse{
+		Type:  "response.output_text.delta",
+		Delta: "more",
+	})
+	require.Empty(t, chunks)
+	require.NotNil(t, oaiErr)
+}
+
+func TestResponsesToChatStreamRefusal(t *testing.T) {
+	state := NewResponsesToChatStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	var refusals []string
+	for _, raw := range []string{
+		`{"type":"response.content_part.added","item_id":"msg_1","output_index":0,"content_index":0,"part":{"type":"refusal","refusal":""}}`,
+		`{"type":"response.refusal.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"I can't "}`,
+		`{"type":"response.refusal.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"help with that."}`,
+		`{"type":"response.refusal.done","item_id":"msg_1","output_index":0,"content_index":0,"refusal":"I can't help with that."}`,
+		`{"type":"response.completed","response":{"id":"resp_1","status":"completed"}}`,
+	} {
+		var event dto.ResponsesStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &event))
+		chunks, oaiErr := state.HandleResponsesEvent(&event)
+		require.Nil(t, oaiErr)
+		for _, chunk := range chunks {
+			if refusal := chunk.Choices[0].Delta.Refusal; refusal != nil {
+				refusals = append(refusals, *refusal)
+			}
+		}
+	}
+
+	require.Equal(t, []string{"I can't ", "help with that."}, refusals)
+	require.Equal(t, "I can't help with that.", state.RefusalText.String())
+	require.True(t, state.SentStop)
+}
+
+func TestResponsesToChatStreamReasoningItem(t *testing.T) {
+	state := NewResponsesToChatStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+
+	var chunks []dto.ChatCompletionsStreamResponse
+	for _, raw := range []string{
+		`{"type":"response.output_item.added","output_index":0,"item":{"id":"rs_1","type":"reasoning","status":"in_progress","summary":[]}}`,
+		`{"type":"response.reasoning_summary_part.added","item_id":"rs_1","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`,
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","output_index":0,"summary_index":0,"delta":"Checking "}`,
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","output_index":0,"summary_index":0,"delta":"the"}`,
+		`{"type":"response.reasoning_summary_text.done","item_id":"rs_1","output_index":0,"summary_index":0,"text":"Checking the forecast."}`,
+		`{"type":"response.output_item.done","output_index":0,"item":{"id":"rs_1","type":"reasoning","status":"completed","summary":[{"type":"summary_text","text":"Checking the forecast."}]}}`,
+	} {
+		var event dto.ResponsesStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(raw, &event))
+		out, oaiErr := state.HandleResponsesEvent(&event)
+		require.Nil(t, oaiErr)
+		chunks = append(chunks, out...)
+	}
+
+	require.Len(t, chunks, 4)
+	require.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	var reasoning []string
+	for _, chunk := range chunks[1:] {
+		delta := chunk.Choices[0].Delta
+		require.Nil(t, delta.Content)
+		require.Empty(t, delta.ToolCalls)
+		require.Nil(t, chunk.Choices[0].FinishReason)
+		require.NotNil(t, delta.ReasoningContent)
+		reasoning = append(reasoning, *delta.ReasoningContent)
+	}
+	require.Equal(t, []string{"Checking ", "the", " forecast."}, reasoning)
+	require.Zero(t, state.OutputText.Len())
+	require.False(t, state.SawToolCall)
+}
+
+func TestSystemFingerprintPropagation(t *testing.T) {
+	chatState := NewChatToResponsesStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	var chunk dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{"system_fingerprint":"fp_abc123","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`, &chunk))
+	events := chatState.HandleChatChunk(&chunk)
+	events = append(events, chatState.FinalEvents(nil)...)
+
+	var sawCreated, sawCompleted bool
+	for _, event := range events {
+		switch event.Type {
+		case "response.created":
+			sawCreated = true
+			require.Equal(t, "fp_abc123", event.Response.SystemFingerprint)
+		case "response.completed":
+			sawCompleted = true
+			require.Equal(t, "fp_abc123", event.Response.SystemFingerprint)
+		}
+	}
+	require.True(t, sawCreated)
+	require.True(t, sawCompleted)
+
+	state := NewResponsesToChatStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	var chunks []dto.ChatCompletionsStreamResponse
+	for _, event := range events {
+		out, oaiErr := state.HandleResponsesEvent(&event)
+		require.Nil(t, oaiErr)
+		chunks = append(chunks, out...)
+	}
+	require.NotEmpty(t, chunks)
+	for _, chatChunk := range chunks {
+		require.Equal(t, "fp_abc123", chatChunk.GetSystemFingerprint())
+	}
+
+	resp := &dto.OpenAITextResponse{
+		Id:                "chatcmpl-1",
+		Model:             "gpt-4.1",
+		SystemFingerprint: "fp_abc123",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Message: dto.Message{Role: "assistant", Content: "Hi"}, FinishReason: "stop"},
+		},
+	}
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(resp, "gpt-4.1")
+	require.NoError(t, err)
+	require.Equal(t, "fp_abc123", responsesResp.SystemFingerprint)
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(responsesResp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "fp_abc123", chatResp.SystemFingerprint)
+}
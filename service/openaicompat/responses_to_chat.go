@@ -1,79 +1,256 @@
 package openaicompat
 
 import (
-	"github.com/QuantumNous/new-api/i18n"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/samber/lo"
 )
 
 func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesResponse, id string) (*dto.OpenAITextResponse, *dto.Usage, error) {
+	return ResponsesResponseToChatCompletionsResponseWithOptions(resp, id, ResponsesToChatOptions{})
+}
+
+func ResponsesResponseToChatCompletionsResponseWithOptions(resp *dto.OpenAIResponsesResponse, id string, opts ResponsesToChatOptions) (*dto.OpenAITextResponse, *dto.Usage, error) {
 	if resp == nil {
 		return nil, nil, errors.New(i18n.Translate("svc.response_is_nil"))
 	}
 
-	text := ExtractOutputTextFromResponses(resp)
+	usage := responsesUsageToChatUsage(resp.Usage)
+	if resp.Usage == nil && opts.EstimateUsage != nil {
+		usage = responsesUsageToChatUsage(opts.EstimateUsage(resp))
+	}
 
-	usage := &dto.Usage{}
-	if resp.Usage != nil {
-		if resp.Usage.InputTokens != 0 {
-			usage.PromptTokens = resp.Usage.InputTokens
-			usage.InputTokens = resp.Usage.InputTokens
-		}
-		if resp.Usage.OutputTokens != 0 {
-			usage.CompletionTokens = resp.Usage.OutputTokens
-			usage.OutputTokens = resp.Usage.OutputTokens
-		}
-		if resp.Usage.TotalTokens != 0 {
-			usage.TotalTokens = resp.Usage.TotalTokens
-		} else {
-			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	created := resp.CreatedAt
+
+	// Multiple candidates (n>1) are represented as separate assistant message
+	// items, each followed by its own tool calls. Without n>1 several message
+	// items (a preamble and the answer, or text around tool calls) are one
+	// choice.
+	var groupsBuf [][]dto.ResponsesOutput
+	if opts.scratch != nil {
+		groupsBuf = opts.scratch.groups[:0]
+	}
+	groups := splitResponsesOutputByChoice(sortResponsesOutputByIndex(resp.Output), opts.N, groupsBuf)
+	if opts.scratch != nil {
+		opts.scratch.groups = groups
+	}
+	choices := make([]dto.OpenAITextResponseChoice, 0, len(groups))
+	status := resp.GetStatus()
+	// A response that finished as incomplete (e.g. hit max_output_tokens)
+	// keeps its truncated text; only mid-generation snapshots are filtered.
+	includeInProgress := opts.IncludeInProgressText || status == "incomplete"
+	for i, group := range groups {
+		choice := responsesOutputToChatChoice(i, group, includeInProgress, opts)
+		if status == "requires_action" {
+			// The response waits for tool outputs even when its tool call
+			// items were already consumed, so agent loops keep going.
+			choice.FinishReason = "tool_calls"
 		}
-		if resp.Usage.InputTokensDetails != nil {
-			usage.PromptTokensDetails.CachedTokens = resp.Usage.InputTokensDetails.CachedTokens
-			usage.PromptTokensDetails.ImageTokens = resp.Usage.InputTokensDetails.ImageTokens
-			usage.PromptTokensDetails.AudioTokens = resp.Usage.InputTokensDetails.AudioTokens
+		choices = append(choices, choice)
+	}
+
+	out := &dto.OpenAITextResponse{
+		Id:                id,
+		Object:            "chat.completion",
+		Created:           created,
+		Model:             resp.Model,
+		Choices:           choices,
+		ServiceTier:       resp.ServiceTier,
+		SystemFingerprint: resp.SystemFingerprint,
+		Usage:             *usage,
+	}
+
+	return out, usage, nil
+}
+
+// sortResponsesOutputByIndex orders output items by their explicit
+// output_index. Items without one keep their array position as the sort key.
+func sortResponsesOutputByIndex(output []dto.ResponsesOutput) []dto.ResponsesOutput {
+	if !lo.SomeBy(output, func(item dto.ResponsesOutput) bool { return item.OutputIndex != nil }) {
+		return output
+	}
+	return sortStableByKey(output, func(i int) int { return lo.FromPtrOr(output[i].OutputIndex, i) })
+}
+
+// sortStableByKey returns a copy of items ordered by key(i), where i is the
+// original position; ties keep their array order.
+func sortStableByKey[T any](items []T, key func(i int) int) []T {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return key(order[a]) < key(order[b]) })
+	sorted := make([]T, 0, len(items))
+	for _, i := range order {
+		sorted = append(sorted, items[i])
+	}
+	return sorted
+}
+
+// responsesUsageToChatUsage maps a Responses usage block onto the Chat usage
+// fields. A nil input yields an empty usage.
+func responsesUsageToChatUsage(src *dto.Usage) *dto.Usage {
+	usage := &dto.Usage{}
+	if src == nil {
+		return usage
+	}
+	if src.InputTokens != 0 {
+		usage.PromptTokens = src.InputTokens
+		usage.InputTokens = src.InputTokens
+	}
+	if src.OutputTokens != 0 {
+		usage.CompletionTokens = src.OutputTokens
+		usage.OutputTokens = src.OutputTokens
+	}
+	if src.TotalTokens != 0 {
+		usage.TotalTokens = src.TotalTokens
+	} else {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if src.InputTokensDetails != nil {
+		usage.PromptTokensDetails.CachedTokens = src.InputTokensDetails.CachedTokens
+		usage.PromptTokensDetails.ImageTokens = src.InputTokensDetails.ImageTokens
+		usage.PromptTokensDetails.AudioTokens = src.InputTokensDetails.AudioTokens
+	}
+	if src.CompletionTokenDetails.ReasoningTokens != 0 {
+		usage.CompletionTokenDetails.ReasoningTokens = src.CompletionTokenDetails.ReasoningTokens
+	}
+	if src.OutputTokensDetails != nil {
+		if src.OutputTokensDetails.ReasoningTokens != 0 {
+			usage.CompletionTokenDetails.ReasoningTokens = src.OutputTokensDetails.ReasoningTokens
 		}
-		if resp.Usage.CompletionTokenDetails.ReasoningTokens != 0 {
-			usage.CompletionTokenDetails.ReasoningTokens = resp.Usage.CompletionTokenDetails.ReasoningTokens
+		usage.CompletionTokenDetails.AudioTokens = src.OutputTokensDetails.AudioTokens
+	}
+	return usage
+}
+
+// splitResponsesOutputByChoice groups output items into one slice per Chat
+// choice. When n is above 1, every assistant message item after the first
+// starts a new group, up to n groups; other items stay with the message they
+// follow, except reasoning items, which belong to the message after them.
+// Otherwise the whole output is a single group. The groups are appended to
+// buf, which may be nil.
+func splitResponsesOutputByChoice(output []dto.ResponsesOutput, n int, buf [][]dto.ResponsesOutput) [][]dto.ResponsesOutput {
+	if n <= 1 {
+		return append(buf, output)
+	}
+	groups := append(buf, nil)
+	hasMessage := false
+	for _, out := range output {
+		if out.Type == "message" && (out.Role == "" || out.Role == "assistant") {
+			if hasMessage && len(groups) < n {
+				// Reasoning right before this message is part of its turn.
+				prev := groups[len(groups)-1]
+				split := len(prev)
+				for split > 0 && prev[split-1].Type == "reasoning" {
+					split--
+				}
+				groups[len(groups)-1] = prev[:split]
+				groups = append(groups, append([]dto.ResponsesOutput(nil), prev[split:]...))
+			}
+			hasMessage = true
 		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], out)
 	}
+	return groups
+}
 
-	created := resp.CreatedAt
+func responsesOutputToChatChoice(index int, output []dto.ResponsesOutput, includeInProgress bool, opts ResponsesToChatOptions) dto.OpenAITextResponseChoice {
+	group := &dto.OpenAIResponsesResponse{Output: output}
 
-	var toolCalls []dto.ToolCallResponse
-	if len(resp.Output) > 0 {
-		for _, out := range resp.Output {
-			if out.Type != "function_call" {
-				continue
+	text := ExtractOutputTextFromResponsesWithOptions(group, ExtractOutputTextOptions{
+		Separator:         opts.OutputTextSeparator,
+		IncludeInProgress: includeInProgress,
+	})
+	audio := extractOutputAudioFromResponses(group)
+	if audio != nil && text == audio.Transcript {
+		// Chat keeps the transcript on the audio object, not in content.
+		text = ""
+	}
+	// The artifacts are not the model's words and clients replay content
+	// into later prompts, so they are only inlined on request.
+	if opts.InlineCodeInterpreterOutputs {
+		if artifacts := extractCodeInterpreterOutputsFromResponses(group); artifacts != "" {
+			// Interpreter calls run before the final answer, so keep their
+			// artifacts ahead of the assistant text.
+			if text != "" {
+				artifacts += "\n\n"
 			}
-			name := strings.TrimSpace(out.Name)
-			if name == "" {
-				continue
+			text = artifacts + text
+		}
+	}
+
+	var toolCalls []dto.ToolCallResponse
+	if opts.scratch != nil {
+		// SetToolCalls copies the calls into JSON, so the slice is free to
+		// reuse once the choice is built.
+		toolCalls = opts.scratch.toolCalls[:0]
+		defer func() { opts.scratch.toolCalls = toolCalls[:0] }()
+	}
+	// mcp_call items were already executed upstream; they are surfaced for
+	// visibility but do not by themselves ask the client to act.
+	needsClientAction := false
+	annotations := responsesOutputTextAnnotations(output)
+	for _, out := range output {
+		if out.Type == dto.ResponsesOutputTypeFileSearchCall {
+			annotations = appendFileSearchAnnotations(annotations, out.Results)
+			if opts.FileSearchToolCalls {
+				toolCalls = append(toolCalls, responsesFileSearchToToolCall(out))
 			}
-			callId := strings.TrimSpace(out.CallId)
-			if callId == "" {
-				callId = strings.TrimSpace(out.ID)
-			}
-			toolCalls = append(toolCalls, dto.ToolCallResponse{
-				ID:   callId,
-				Type: "function",
-				Function: dto.FunctionResponse{
-					Name:      name,
-					Arguments: out.ArgumentsString(),
-				},
-			})
+			continue
+		}
+		switch out.Type {
+		case "function_call", "custom_tool_call", dto.ResponsesOutputTypeMcpCall, dto.ResponsesOutputTypeMcpApprovalRequest:
+		default:
+			continue
+		}
+		name := strings.TrimSpace(out.Name)
+		if name == "" {
+			continue
+		}
+		if out.Type == dto.ResponsesOutputTypeMcpCall || out.Type == dto.ResponsesOutputTypeMcpApprovalRequest {
+			toolCalls = append(toolCalls, responsesMcpItemToToolCall(out, name))
+			needsClientAction = needsClientAction || out.Type == dto.ResponsesOutputTypeMcpApprovalRequest
+			continue
+		}
+		needsClientAction = true
+		callId := strings.TrimSpace(out.CallId)
+		if callId == "" {
+			callId = strings.TrimSpace(out.ID)
+		}
+		toolCall := dto.ToolCallResponse{
+			ID:   callId,
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      name,
+				Arguments: out.ArgumentsString(),
+			},
+		}
+		if out.Type == "custom_tool_call" {
+			// Custom tools take free-form input; keep it verbatim as the arguments.
+			toolCall.Type = "custom"
+			toolCall.Function.Arguments = out.Input
 		}
+		toolCalls = append(toolCalls, toolCall)
 	}
 
 	finishReason := "stop"
-	if len(toolCalls) > 0 {
+	if needsClientAction {
 		finishReason = "tool_calls"
+	} else if lo.SomeBy(output, func(out dto.ResponsesOutput) bool { return out.Type == "message" && out.Status == "incomplete" }) {
+		// The message was cut off, typically by max_output_tokens.
+		finishReason = "length"
 	}
 
 	msg := dto.Message{
@@ -83,35 +260,571 @@ func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesRespons
 	if len(toolCalls) > 0 {
 		msg.SetToolCalls(toolCalls)
 	}
+	msg.Annotations = annotations
+	msg.Audio = audio
+	var reasoningBuf *bytes.Buffer
+	if opts.scratch != nil {
+		reasoningBuf = &opts.scratch.reasoning
+	}
+	msg.ReasoningContent = responsesReasoningText(output, reasoningBuf)
 
-	out := &dto.OpenAITextResponse{
-		Id:      id,
-		Object:  "chat.completion",
-		Created: created,
-		Model:   resp.Model,
-		Choices: []dto.OpenAITextResponseChoice{
-			{
-				Index:        0,
-				Message:      msg,
-				FinishReason: finishReason,
+	return dto.OpenAITextResponseChoice{
+		Index:        index,
+		Message:      msg,
+		FinishReason: finishReason,
+	}
+}
+
+// responsesReasoningText merges the readable reasoning of every reasoning
+// item, in output order, into one reasoning_content string. An item's summary
+// parts are joined as streamed; items without a summary fall back to their
+// reasoning_text content. Items are separated by a blank line. buf, when not
+// nil, is the scratch buffer to build the text in.
+func responsesReasoningText(output []dto.ResponsesOutput, buf *bytes.Buffer) string {
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	buf.Reset()
+	for _, out := range output {
+		if out.Type != "reasoning" {
+			continue
+		}
+		start := buf.Len()
+		if start > 0 {
+			buf.WriteString("\n\n")
+		}
+		textStart := buf.Len()
+		for _, part := range out.Summary {
+			buf.WriteString(part.Text)
+		}
+		if buf.Len() == textStart {
+			for _, part := range out.Content {
+				if part.Type == "reasoning_text" {
+					buf.WriteString(part.Text)
+				}
+			}
+		}
+		if buf.Len() == textStart {
+			// Nothing readable; drop the separator again.
+			buf.Truncate(start)
+		}
+	}
+	return buf.String()
+}
+
+// functionToolNamePattern is the function name constraint enforced by
+// OpenAI-compatible upstreams.
+var functionToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// convertResponsesInputImage maps a Responses input_image part, which may
+// reference the image by URL, by file_id or both, plus an optional detail,
+// onto a Chat image_url value.
+func convertResponsesInputImage(partMap map[string]any) any {
+	img := &dto.MessageImageUrl{
+		Detail: common.Interface2String(partMap["detail"]),
+		FileId: common.Interface2String(partMap["file_id"]),
+	}
+	switch iu := partMap["image_url"].(type) {
+	case string:
+		img.Url = iu
+	case map[string]any:
+		img.Url = common.Interface2String(iu["url"])
+		if img.Detail == "" {
+			img.Detail = common.Interface2String(iu["detail"])
+		}
+	case nil:
+	default:
+		return iu
+	}
+	return img
+}
+
+// expandResponsesImageFrames replaces each multi-frame input_image part with
+// one input_image part per frame. A frame is an image URL or an object with
+// image_url, url or file_id; frames without their own detail inherit the
+// part's.
+func expandResponsesImageFrames(content any, opts ResponsesToChatOptions) any {
+	parts, ok := content.([]any)
+	if !ok {
+		return content
+	}
+	expanded := make([]any, 0, len(parts))
+	for _, part := range parts {
+		partMap, ok := part.(map[string]any)
+		frames, hasFrames := partMap["frames"].([]any)
+		if !ok || partMap["type"] != "input_image" || !hasFrames {
+			expanded = append(expanded, part)
+			continue
+		}
+		if !opts.ExpandImageFrames {
+			opts.drop("input[].content[].frames", "multi-frame input_image")
+			expanded = append(expanded, part)
+			continue
+		}
+		for _, frame := range frames {
+			framePart := map[string]any{"type": "input_image"}
+			switch f := frame.(type) {
+			case string:
+				framePart["image_url"] = f
+			case map[string]any:
+				for _, key := range []string{"image_url", "file_id", "detail"} {
+					if value, ok := f[key]; ok {
+						framePart[key] = value
+					}
+				}
+				if _, ok := framePart["image_url"]; !ok {
+					if url, ok := f["url"]; ok {
+						framePart["image_url"] = url
+					}
+				}
+			default:
+				continue
+			}
+			if _, ok := framePart["detail"]; !ok && partMap["detail"] != nil {
+				framePart["detail"] = partMap["detail"]
+			}
+			expanded = append(expanded, framePart)
+		}
+	}
+	return expanded
+}
+
+// normalizeWebSearchFilters returns a web_search tool with its domain filters
+// in the {"filters": {"allowed_domains": [...], "blocked_domains": [...]}}
+// shape. Lists sent on the tool itself or as comma separated strings are
+// moved there, and each domain is reduced to a lowercase host. Empty lists
+// and empty filters are omitted.
+func normalizeWebSearchFilters(tool map[string]any) map[string]any {
+	filters, _ := tool["filters"].(map[string]any)
+	normalized := make(map[string]any, len(filters))
+	for key, value := range filters {
+		normalized[key] = value
+	}
+	out := make(map[string]any, len(tool))
+	for key, value := range tool {
+		out[key] = value
+	}
+	delete(out, "filters")
+	for _, key := range []string{"allowed_domains", "blocked_domains"} {
+		value, ok := normalized[key]
+		if topLevel, found := out[key]; found {
+			delete(out, key)
+			if !ok {
+				value = topLevel
+			}
+		}
+		domains := normalizeSearchDomains(value)
+		if len(domains) == 0 {
+			delete(normalized, key)
+			continue
+		}
+		normalized[key] = domains
+	}
+	if len(normalized) > 0 {
+		out["filters"] = normalized
+	}
+	return out
+}
+
+// normalizeSearchDomains turns a domain list, given as an array or a comma
+// separated string, into deduplicated lowercase hosts without scheme or path.
+func normalizeSearchDomains(value any) []string {
+	var raw []string
+	switch v := value.(type) {
+	case string:
+		raw = strings.Split(v, ",")
+	case []any:
+		for _, item := range v {
+			if domain, ok := item.(string); ok {
+				raw = append(raw, domain)
+			}
+		}
+	}
+	domains := make([]string, 0, len(raw))
+	for _, domain := range raw {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if i := strings.Index(domain, "://"); i >= 0 {
+			domain = domain[i+3:]
+		}
+		domain, _, _ = strings.Cut(domain, "/")
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return lo.Uniq(domains)
+}
+
+// responsesMcpItemToToolCall turns an mcp_call or mcp_approval_request output
+// item into a synthetic Chat tool call named "<server_label>.<name>". Approval
+// requests keep their item type so clients can tell them apart and answer
+// with the item ID.
+func responsesMcpItemToToolCall(out dto.ResponsesOutput, name string) dto.ToolCallResponse {
+	if label := strings.TrimSpace(out.ServerLabel); label != "" {
+		name = label + "." + name
+	}
+	toolType := "function"
+	if out.Type == dto.ResponsesOutputTypeMcpApprovalRequest {
+		toolType = dto.ResponsesOutputTypeMcpApprovalRequest
+	}
+	return dto.ToolCallResponse{
+		ID:   strings.TrimSpace(out.ID),
+		Type: toolType,
+		Function: dto.FunctionResponse{
+			Name:      name,
+			Arguments: out.ArgumentsString(),
+		},
+	}
+}
+
+// responsesOutputTextAnnotations maps the output_text annotations Chat can
+// represent; other annotation types are dropped.
+func responsesOutputTextAnnotations(output []dto.ResponsesOutput) []dto.MessageAnnotation {
+	var annotations []dto.MessageAnnotation
+	for _, out := range output {
+		if out.Type != "message" {
+			continue
+		}
+		for _, part := range out.Content {
+			for _, raw := range part.Annotations {
+				data, err := common.Marshal(raw)
+				if err != nil {
+					continue
+				}
+				var annotation struct {
+					Type string `json:"type"`
+				}
+				if err := common.Unmarshal(data, &annotation); err != nil {
+					continue
+				}
+				switch annotation.Type {
+				case "container_file_citation":
+					var citation dto.MessageContainerFileCitation
+					if common.Unmarshal(data, &citation) == nil {
+						annotations = append(annotations, dto.MessageAnnotation{
+							Type:                  annotation.Type,
+							ContainerFileCitation: &citation,
+						})
+					}
+				case "url_citation":
+					var citation dto.MessageURLCitation
+					if common.Unmarshal(data, &citation) == nil {
+						annotations = append(annotations, dto.MessageAnnotation{
+							Type:        annotation.Type,
+							URLCitation: &citation,
+						})
+					}
+				}
+			}
+		}
+	}
+	return annotations
+}
+
+// appendFileSearchAnnotations adds one file_citation per retrieved file,
+// skipping files that are already cited.
+func appendFileSearchAnnotations(annotations []dto.MessageAnnotation, results []dto.ResponsesFileSearchResult) []dto.MessageAnnotation {
+	for _, result := range results {
+		if result.FileId == "" {
+			continue
+		}
+		if lo.ContainsBy(annotations, func(a dto.MessageAnnotation) bool {
+			return a.FileCitation != nil && a.FileCitation.FileId == result.FileId
+		}) {
+			continue
+		}
+		annotations = append(annotations, dto.MessageAnnotation{
+			Type: "file_citation",
+			FileCitation: &dto.MessageFileCitation{
+				FileId:   result.FileId,
+				Filename: result.Filename,
 			},
+		})
+	}
+	return annotations
+}
+
+// responsesFileSearchToToolCall surfaces an upstream file search as a
+// synthetic "file_search" tool call carrying its queries.
+func responsesFileSearchToToolCall(out dto.ResponsesOutput) dto.ToolCallResponse {
+	args, _ := common.Marshal(map[string]any{"queries": out.Queries})
+	return dto.ToolCallResponse{
+		ID:   strings.TrimSpace(out.ID),
+		Type: "function",
+		Function: dto.FunctionResponse{
+			Name:      "file_search",
+			Arguments: string(args),
 		},
-		Usage: *usage,
 	}
+}
 
-	return out, usage, nil
+// convertResponsesCustomTool maps a Responses custom tool, whose grammar
+// syntax and definition sit directly on format, onto the Chat shape.
+func convertResponsesCustomTool(tool map[string]any) dto.ToolCallRequest {
+	custom := dto.CustomToolRequest{
+		Name:        common.Interface2String(tool["name"]),
+		Description: common.Interface2String(tool["description"]),
+	}
+	if format, ok := tool["format"].(map[string]any); ok {
+		custom.Format = &dto.CustomToolFormat{Type: common.Interface2String(format["type"])}
+		if custom.Format.Type == "grammar" {
+			// Some clients nest the spec in the Chat shape ({grammar:{...}}).
+			grammar := format
+			if nested, ok := format["grammar"].(map[string]any); ok {
+				grammar = nested
+			}
+			custom.Format.Grammar = &dto.CustomToolGrammar{
+				Syntax:     strings.ToLower(common.Interface2String(grammar["syntax"])),
+				Definition: common.Interface2String(grammar["definition"]),
+			}
+		}
+	}
+	var out dto.ToolCallRequest
+	out.SetCustomTool(custom)
+	return out
+}
+
+// ResponsesToChatOptions tunes the Responses to Chat request and response
+// conversions.
+type ResponsesToChatOptions struct {
+	// Strict turns recoverable problems in the request (such as duplicate
+	// tool names) into errors. When false the offending part is dropped and
+	// reported through OnDrop. Strict also validates the converted request
+	// with ValidateChatRequest, rejecting image URLs upstreams cannot fetch.
+	Strict bool
+	// OnDrop, if set, is called for every part of the request that lenient
+	// conversion discards. path identifies the part, e.g. "tools[2]".
+	OnDrop func(path string, reason string)
+	// FileSearchToolCalls surfaces file_search_call output items as
+	// synthetic tool calls in addition to their file_citation annotations.
+	FileSearchToolCalls bool
+	// InlineCodeInterpreterOutputs renders code_interpreter_call artifacts
+	// as markdown ahead of the assistant content: logs as fenced code
+	// blocks, images as image links. Off by default, as the content then no
+	// longer is only what the model wrote.
+	InlineCodeInterpreterOutputs bool
+	// MaxInstructionChars caps the length of instructions, in characters.
+	// Longer instructions are truncated with an ellipsis marker, or rejected
+	// under Strict. Zero means unlimited.
+	MaxInstructionChars int
+	// SetMaxTokens also sets the legacy max_tokens from max_output_tokens,
+	// for backends that ignore max_completion_tokens.
+	SetMaxTokens bool
+	// OutputTextSeparator joins the output_text parts of a response message;
+	// see ExtractOutputTextOptions.Separator.
+	OutputTextSeparator string
+	// IncludeInProgressText keeps the text of message items that are not
+	// completed; see ExtractOutputTextOptions.IncludeInProgress.
+	IncludeInProgressText bool
+	// PromptResolver expands a stored prompt ({prompt:{id, version,
+	// variables}}) into instructions and input. Requests that reference a
+	// prompt fail when it is nil.
+	PromptResolver PromptResolver
+	// ToolMessageImages keeps image and file parts of a function_call_output
+	// in the tool message, for upstreams that accept media in tool content.
+	ToolMessageImages bool
+	// ItemResolver inlines item_reference input items. Without it references
+	// are skipped and reported through OnDrop.
+	ItemResolver ItemResolver
+	// MaxToolCalls forwards max_tool_calls to upstreams that enforce it.
+	// Otherwise it is dropped and reported through OnDrop.
+	MaxToolCalls bool
+	// PassthroughUnknownFields keeps unknown fields of message input items,
+	// such as vendor extension blocks, on the converted message's Extensions.
+	PassthroughUnknownFields bool
+	// DowngradeStream sends a streaming request to the Chat backend as a
+	// non-stream one, for backends that cannot stream.
+	DowngradeStream bool
+	// EmptyContentArray decides what happens to message items sent with
+	// "content": []. They are kept as empty messages by default.
+	EmptyContentArray EmptyContentArrayPolicy
+	// Info, when set, receives facts about the conversion the converted
+	// request itself no longer shows.
+	Info *ConversionInfo
+	// ExpandImageFrames turns an input_image carrying a "frames" array, as
+	// some clients send for animated or video-like input, into one image part
+	// per frame. Otherwise the frames are dropped and reported through OnDrop.
+	ExpandImageFrames bool
+	// EstimateUsage supplies the usage of a response whose upstream omitted
+	// it, so the converted response is not billed as zero tokens.
+	EstimateUsage UsageEstimator
+	// N is the number of choices the Chat request asked for. Only when it is
+	// above 1 are separate assistant message items of a response turned into
+	// separate choices.
+	N int
+
+	// scratch, when set, holds buffers reused across conversions; see
+	// BatchResponsesToChat.
+	scratch *responsesToChatScratch
+}
+
+// UsageEstimator estimates the token usage of a Responses response, in the
+// Responses usage shape (input_tokens/output_tokens).
+type UsageEstimator func(resp *dto.OpenAIResponsesResponse) *dto.Usage
+
+// EmptyContentArrayPolicy is the handling of message items whose content is
+// an empty array.
+type EmptyContentArrayPolicy string
+
+const (
+	// EmptyContentArrayKeep keeps the message with empty content.
+	EmptyContentArrayKeep EmptyContentArrayPolicy = ""
+	// EmptyContentArrayDrop drops the message and reports it through OnDrop.
+	EmptyContentArrayDrop EmptyContentArrayPolicy = "drop"
+	// EmptyContentArrayUserTurn replaces the message with an empty user turn;
+	// consecutive ones collapse into one.
+	EmptyContentArrayUserTurn EmptyContentArrayPolicy = "user_turn"
+)
+
+// ConversionInfo records how a Responses request was converted.
+type ConversionInfo struct {
+	// StreamRequested is the stream flag of the original request.
+	StreamRequested bool
+	// StreamDowngraded is set when a streaming request was converted into a
+	// non-stream one because of DowngradeStream.
+	StreamDowngraded bool
+	// ServiceTier is the normalized service tier carried into the Chat
+	// request, so routing can pick a matching pool.
+	ServiceTier string
+}
+
+// knownMessageItemFields are the message input item fields the conversion
+// reads.
+var knownMessageItemFields = map[string]bool{
+	"type":       true,
+	"id":         true,
+	"role":       true,
+	"name":       true,
+	"content":    true,
+	"status":     true,
+	"tool_calls": true,
+}
+
+// unknownMessageItemFields returns the fields of a message input item the
+// conversion does not understand, or nil when there are none.
+func unknownMessageItemFields(item map[string]any) map[string]json.RawMessage {
+	var extensions map[string]json.RawMessage
+	for key, value := range item {
+		if knownMessageItemFields[key] {
+			continue
+		}
+		raw, err := common.Marshal(value)
+		if err != nil {
+			continue
+		}
+		if extensions == nil {
+			extensions = make(map[string]json.RawMessage)
+		}
+		extensions[key] = raw
+	}
+	return extensions
+}
+
+// ItemResolver loads a stored Responses item by id, returning it in the input
+// item shape, or nil when it does not exist.
+type ItemResolver func(id string) (json.RawMessage, error)
+
+// resolveResponsesItemReferences replaces item_reference input items with the
+// items they point at.
+func resolveResponsesItemReferences(items []map[string]any, opts ResponsesToChatOptions) ([]map[string]any, error) {
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if itemType, _ := item["type"].(string); itemType != "item_reference" {
+			out = append(out, item)
+			continue
+		}
+		id, _ := item["id"].(string)
+		if opts.ItemResolver == nil {
+			opts.drop("input[].item_reference", fmt.Sprintf("no item resolver for reference %q", id))
+			continue
+		}
+		raw, err := opts.ItemResolver(id)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.Translate("svc.failed_to_resolve_item_reference"), id, err)
+		}
+		var resolved map[string]any
+		if common.GetJsonType(raw) != "object" || common.Unmarshal(raw, &resolved) != nil {
+			return nil, fmt.Errorf(i18n.Translate("svc.item_reference_not_found"), id)
+		}
+		out = append(out, resolved)
+	}
+	return out, nil
+}
+
+func (o ResponsesToChatOptions) drop(path string, reason string) {
+	if o.OnDrop != nil {
+		o.OnDrop(path, reason)
+	}
+}
+
+// knownServiceTiers are the service_tier values accepted by OpenAI
+// compatible upstreams.
+var knownServiceTiers = map[string]bool{
+	"auto":     true,
+	"default":  true,
+	"flex":     true,
+	"scale":    true,
+	"priority": true,
+}
+
+// ResponsesTruncation returns the effective truncation strategy to echo on a
+// Responses envelope: the requested one, or "disabled", the API default.
+func ResponsesTruncation(requested json.RawMessage) json.RawMessage {
+	if common.GetJsonType(requested) == "string" {
+		return requested
+	}
+	return json.RawMessage(`"disabled"`)
+}
+
+// ChatRequestEndUserID returns the identifier abuse detection should key on:
+// safety_identifier when set, otherwise user. Conversions forward both fields
+// unchanged, so the choice is the same on either side of a conversion.
+func ChatRequestEndUserID(req *dto.GeneralOpenAIRequest) string {
+	if req == nil {
+		return ""
+	}
+	for _, raw := range []json.RawMessage{req.SafetyIdentifier, req.User} {
+		var id string
+		if common.GetJsonType(raw) == "string" && common.Unmarshal(raw, &id) == nil && strings.TrimSpace(id) != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+const instructionsTruncationMarker = "…"
+
+// truncateInstructions shortens s to at most maxChars characters, including
+// the trailing truncation marker.
+func truncateInstructions(s string, maxChars int) string {
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	keep := maxChars - utf8.RuneCountInString(instructionsTruncationMarker)
+	if keep < 0 {
+		keep = 0
+	}
+	return string(runes[:keep]) + instructionsTruncationMarker
 }
 
 // ResponsesRequestToChatCompletionsRequest converts a Responses API request
 // to a Chat Completions API request. This is the inverse of
 // ChatCompletionsRequestToResponsesRequest in chat_to_responses.go.
 func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	return ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{})
+}
+
+func ResponsesRequestToChatCompletionsRequestWithOptions(req *dto.OpenAIResponsesRequest, opts ResponsesToChatOptions) (*dto.GeneralOpenAIRequest, error) {
 	if req == nil {
 		return nil, errors.New(i18n.Translate("svc.request_is_nil"))
 	}
 	if req.Model == "" {
 		return nil, errors.New(i18n.Translate("svc.model_is_required"))
 	}
+	req, err := expandResponsesPrompt(req, opts.PromptResolver)
+	if err != nil {
+		return nil, err
+	}
 
 	var messages []dto.Message
 
@@ -119,6 +832,13 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	if len(req.Instructions) > 0 {
 		var instructions string
 		if err := common.Unmarshal(req.Instructions, &instructions); err == nil && strings.TrimSpace(instructions) != "" {
+			if opts.MaxInstructionChars > 0 && utf8.RuneCountInString(instructions) > opts.MaxInstructionChars {
+				if opts.Strict {
+					return nil, fmt.Errorf(i18n.Translate("svc.instructions_too_long"), opts.MaxInstructionChars)
+				}
+				instructions = truncateInstructions(instructions, opts.MaxInstructionChars)
+				opts.drop("instructions", fmt.Sprintf("truncated to %d characters", opts.MaxInstructionChars))
+			}
 			messages = append(messages, dto.Message{
 				Role:    "system",
 				Content: instructions,
@@ -144,6 +864,11 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 				return nil, fmt.Errorf(i18n.Translate("svc.failed_to_parse_input"), err)
 			}
 
+			inputItems, err := resolveResponsesItemReferences(inputItems, opts)
+			if err != nil {
+				return nil, err
+			}
+
 			// Collect consecutive function_call items to merge into one assistant message
 			var pendingToolCalls []dto.ToolCallResponse
 
@@ -165,6 +890,14 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 				role, _ := item["role"].(string)
 
 				switch {
+				case itemType == "reasoning":
+					// Encrypted reasoning state is only meaningful to the
+					// Responses backend that issued it; Chat has no field
+					// for it, so echoed reasoning is not supported here.
+					if encrypted, _ := item["encrypted_content"].(string); encrypted != "" {
+						opts.drop("input[].encrypted_content", "encrypted reasoning is not supported by Chat upstreams")
+					}
+
 				case itemType == "function_call":
 					callID, _ := item["call_id"].(string)
 					name, _ := item["name"].(string)
@@ -178,10 +911,57 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 						},
 					})
 
-				case itemType == "function_call_output":
+				case itemType == "custom_tool_call":
+					callID, _ := item["call_id"].(string)
+					name, _ := item["name"].(string)
+					input, _ := item["input"].(string)
+					pendingToolCalls = append(pendingToolCalls, dto.ToolCallResponse{
+						ID:   callID,
+						Type: "custom",
+						Function: dto.FunctionResponse{
+							Name:      name,
+							Arguments: input,
+						},
+					})
+
+				case itemType == dto.ResponsesOutputTypeFileSearchCall:
+					// A replayed retrieval becomes a synthetic file_search
+					// call answered by its results, so the context survives.
+					var call dto.ResponsesOutput
+					raw, err := common.Marshal(item)
+					if err != nil {
+						return nil, err
+					}
+					if err := common.Unmarshal(raw, &call); err != nil {
+						return nil, err
+					}
+					toolCall := responsesFileSearchToToolCall(call)
+					if toolCall.ID == "" {
+						opts.drop("input[].file_search_call", "file_search_call without an id")
+						continue
+					}
+					pendingToolCalls = append(pendingToolCalls, toolCall)
+					flushToolCalls()
+					if call.Results == nil {
+						call.Results = []dto.ResponsesFileSearchResult{}
+					}
+					results, err := common.Marshal(call.Results)
+					if err != nil {
+						return nil, err
+					}
+					messages = append(messages, dto.Message{
+						Role:       "tool",
+						Content:    string(results),
+						ToolCallId: toolCall.ID,
+					})
+
+				case itemType == "function_call_output" || itemType == "custom_tool_call_output":
 					flushToolCalls()
 					callID, _ := item["call_id"].(string)
-					output := common.Interface2String(item["output"])
+					output, err := functionCallOutputToChat(item["output"], opts)
+					if err != nil {
+						return nil, err
+					}
 					messages = append(messages, dto.Message{
 						Role:       "tool",
 						Content:    output,
@@ -195,8 +975,37 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 						msgRole = "system"
 					}
 					msg := dto.Message{Role: msgRole}
+					if name, _ := item["name"].(string); name != "" {
+						msg.Name = lo.ToPtr(name)
+					}
 					if content, ok := item["content"]; ok {
-						msg.Content = convertResponsesContentToChat(content)
+						content, msg.ReasoningContent = splitResponsesReasoningParts(content)
+						chatContent, err := convertResponsesContentToChat(expandResponsesImageFrames(content, opts))
+						if err != nil {
+							return nil, err
+						}
+						msg.Content = chatContent
+					}
+					msg.Status, _ = item["status"].(string)
+					if opts.PassthroughUnknownFields {
+						msg.Extensions = unknownMessageItemFields(item)
+					}
+					if role == "assistant" {
+						if toolCalls := inlineAssistantToolCalls(item["tool_calls"]); len(toolCalls) > 0 {
+							msg.SetToolCalls(toolCalls)
+						}
+					}
+					if parts, ok := item["content"].([]any); ok && len(parts) == 0 && len(msg.ToolCalls) == 0 {
+						switch opts.EmptyContentArray {
+						case EmptyContentArrayDrop:
+							opts.drop("input[].content", "message with an empty content array")
+							continue
+						case EmptyContentArrayUserTurn:
+							if n := len(messages); n > 0 && messages[n-1].Role == "user" && messages[n-1].Content == "" {
+								continue
+							}
+							msg = dto.Message{Role: "user", Content: ""}
+						}
 					}
 					messages = append(messages, msg)
 
@@ -204,9 +1013,11 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 					flushToolCalls()
 					// Best-effort: treat as user message
 					if content, ok := item["content"]; ok {
-						msg := dto.Message{Role: "user"}
-						msg.Content = convertResponsesContentToChat(content)
-						messages = append(messages, msg)
+						chatContent, err := convertResponsesContentToChat(expandResponsesImageFrames(content, opts))
+						if err != nil {
+							return nil, err
+						}
+						messages = append(messages, dto.Message{Role: "user", Content: chatContent})
 					}
 				}
 			}
@@ -223,12 +1034,30 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 		Messages:             messages,
 		Stream:               req.Stream,
 		User:                 req.User,
+		SafetyIdentifier:     req.SafetyIdentifier,
 		Store:                req.Store,
-		Metadata:             req.Metadata,
+		Metadata:             stringifyMetadata(req.Metadata),
 		PromptCacheRetention: req.PromptCacheRetention,
 	}
+	// "disabled" is the default; only forward a strategy that changes
+	// upstream behavior, as strict Chat upstreams reject unknown fields.
+	if truncation := ResponsesTruncation(req.Truncation); string(truncation) != `"disabled"` {
+		out.Truncation = truncation
+	}
 
-	if len(req.PromptCacheKey) > 0 {
+	if tier := strings.ToLower(strings.TrimSpace(req.ServiceTier)); tier != "" {
+		if knownServiceTiers[tier] {
+			out.ServiceTier, _ = common.Marshal(tier)
+			if opts.Info != nil {
+				opts.Info.ServiceTier = tier
+			}
+		} else if opts.Strict {
+			return nil, fmt.Errorf(i18n.Translate("svc.unsupported_service_tier"), req.ServiceTier)
+		} else {
+			opts.drop("service_tier", fmt.Sprintf("unknown service tier %q", req.ServiceTier))
+		}
+	}
+	if len(req.PromptCacheKey) > 0 {
 		var key string
 		if err := common.Unmarshal(req.PromptCacheKey, &key); err == nil {
 			out.PromptCacheKey = key
@@ -236,6 +1065,9 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	}
 	if req.MaxOutputTokens != nil && *req.MaxOutputTokens > 0 {
 		out.MaxCompletionTokens = req.MaxOutputTokens
+		if opts.SetMaxTokens {
+			out.MaxTokens = lo.ToPtr(*req.MaxOutputTokens)
+		}
 	}
 	if req.Temperature != nil {
 		out.Temperature = req.Temperature
@@ -243,15 +1075,49 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	if req.TopP != nil {
 		out.TopP = req.TopP
 	}
-	if req.Reasoning != nil && req.Reasoning.Effort != "" && req.Reasoning.Effort != "none" {
-		out.ReasoningEffort = req.Reasoning.Effort
+	// top_logprobs alone enables logprobs; the include hint is optional.
+	if req.TopLogProbs != nil {
+		out.LogProbs = lo.ToPtr(true)
+		out.TopLogProbs = req.TopLogProbs
+	} else if lo.Contains(parseResponsesInclude(req.Include), responsesIncludeOutputTextLogprobs) {
+		out.LogProbs = lo.ToPtr(true)
+	}
+	if req.Reasoning != nil && req.Reasoning.Effort != "" {
+		// "minimal" and the other levels pass through as-is. "none" is only
+		// forwarded to models that understand it; elsewhere it is dropped so
+		// the request is not rejected.
+		if req.Reasoning.Effort != "none" || ModelSupportsReasoningEffortNone(req.Model) {
+			out.ReasoningEffort = req.Reasoning.Effort
+		}
+	} else if req.Reasoning != nil && req.Reasoning.EffortBudget != nil {
+		out.ReasoningEffort = ReasoningEffortForBudget(*req.Reasoning.EffortBudget)
+	}
+
+	if opts.Info != nil {
+		opts.Info.StreamRequested = lo.FromPtr(req.Stream)
+	}
+	if lo.FromPtr(req.Stream) && opts.DowngradeStream {
+		// The caller replays the non-stream answer as events, see
+		// ResponsesResponseToStreamEvents.
+		out.Stream = lo.ToPtr(false)
+		if opts.Info != nil {
+			opts.Info.StreamDowngraded = true
+		}
 	}
 
 	// Stream options
-	if req.Stream != nil && *req.Stream {
+	if lo.FromPtr(out.Stream) {
 		out.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
 	}
 
+	if req.MaxToolCalls != nil {
+		if opts.MaxToolCalls {
+			out.MaxToolCalls = lo.ToPtr(*req.MaxToolCalls)
+		} else {
+			opts.drop("max_tool_calls", "the upstream does not enforce a tool call cap")
+		}
+	}
+
 	// ParallelToolCalls
 	if len(req.ParallelToolCalls) > 0 {
 		var ptc bool
@@ -264,25 +1130,56 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	if len(req.Tools) > 0 {
 		var tools []map[string]any
 		if err := common.Unmarshal(req.Tools, &tools); err == nil {
-			for _, tool := range tools {
+			seenFunctionNames := make(map[string]bool, len(tools))
+			for i, tool := range tools {
 				toolType, _ := tool["type"].(string)
 				if toolType == "" {
 					continue
 				}
 				if toolType == "function" {
 					name, _ := tool["name"].(string)
+					if !functionToolNamePattern.MatchString(name) {
+						return nil, fmt.Errorf(i18n.Translate("svc.invalid_function_tool_name"), i, name)
+					}
+					if seenFunctionNames[name] {
+						// Backends reject duplicate function names; keep the first.
+						if opts.Strict {
+							return nil, fmt.Errorf(i18n.Translate("svc.duplicate_function_tool_name"), i, name)
+						}
+						opts.drop(fmt.Sprintf("tools[%d]", i), "duplicate function name "+name)
+						continue
+					}
+					seenFunctionNames[name] = true
 					description, _ := tool["description"].(string)
 					parameters := tool["parameters"]
+					if paramsStr, ok := parameters.(string); ok {
+						// Some clients send the schema JSON-encoded as a string.
+						var paramsObj map[string]any
+						if err := common.UnmarshalJsonStr(paramsStr, &paramsObj); err == nil {
+							parameters = paramsObj
+						}
+					}
+					fn := dto.FunctionRequest{
+						Name:        name,
+						Description: description,
+						Parameters:  parameters,
+					}
+					if strict, ok := tool["strict"].(bool); ok {
+						fn.Strict = &strict
+					}
 					out.Tools = append(out.Tools, dto.ToolCallRequest{
-						Type: "function",
-						Function: dto.FunctionRequest{
-							Name:        name,
-							Description: description,
-							Parameters:  parameters,
-						},
+						Type:     "function",
+						Function: fn,
 					})
 					continue
 				}
+				if toolType == "custom" {
+					out.Tools = append(out.Tools, convertResponsesCustomTool(tool))
+					continue
+				}
+				if strings.HasPrefix(toolType, "web_search") {
+					tool = normalizeWebSearchFilters(tool)
+				}
 				// Non-function tools (web_search_preview, file_search, etc.) — pass through
 				if b, err := common.Marshal(tool); err == nil {
 					out.Tools = append(out.Tools, dto.ToolCallRequest{
@@ -298,27 +1195,19 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	if len(req.ToolChoice) > 0 {
 		var tcStr string
 		if err := common.Unmarshal(req.ToolChoice, &tcStr); err == nil {
-			// String values: "auto", "none", "required"
-			out.ToolChoice = tcStr
+			// String values: "auto", "none", "required". Without tools,
+			// "auto" and "none" are no-ops that some backends reject.
+			if len(out.Tools) > 0 || (tcStr != "auto" && tcStr != "none") {
+				out.ToolChoice = tcStr
+			}
 		} else {
 			var tcMap map[string]any
 			if err := common.Unmarshal(req.ToolChoice, &tcMap); err == nil {
-				tcType, _ := tcMap["type"].(string)
-				if tcType == "function" {
-					// Responses: {"type": "function", "name": "fn_name"}
-					// Chat:      {"type": "function", "function": {"name": "fn_name"}}
-					name, _ := tcMap["name"].(string)
-					if name != "" {
-						out.ToolChoice = map[string]any{
-							"type":     "function",
-							"function": map[string]any{"name": name},
-						}
-					} else {
-						out.ToolChoice = tcMap
-					}
-				} else {
-					out.ToolChoice = tcMap
+				toolChoice, err := convertResponsesToolChoice(tcMap, out.Tools)
+				if err != nil {
+					return nil, err
 				}
+				out.ToolChoice = toolChoice
 			}
 		}
 	}
@@ -326,16 +1215,198 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	// Text (response format)
 	if len(req.Text) > 0 {
 		out.ResponseFormat = convertResponsesTextToResponseFormat(req.Text)
+		if err := defaultJSONSchemaName(out.ResponseFormat, opts.Strict); err != nil {
+			return nil, err
+		}
+		out.Verbosity = responsesTextVerbosity(req.Text)
+	}
+
+	if opts.Strict {
+		if err := ValidateChatRequest(out); err != nil {
+			return nil, err
+		}
 	}
 
 	return out, nil
 }
 
+// inlineAssistantToolCalls reads a tool_calls array embedded in an assistant
+// input item. Entries may use the Chat shape ({id, function:{name,
+// arguments}}) or the flat Responses shape ({call_id, name, arguments}).
+func inlineAssistantToolCalls(raw any) []dto.ToolCallResponse {
+	items, _ := raw.([]any)
+	var toolCalls []dto.ToolCallResponse
+	for _, entry := range items {
+		call, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		fields := call
+		if function, ok := call["function"].(map[string]any); ok {
+			fields = function
+		}
+		name, _ := fields["name"].(string)
+		if name == "" {
+			continue
+		}
+		id, _ := call["id"].(string)
+		if callID, _ := call["call_id"].(string); callID != "" {
+			id = callID
+		}
+		callType, _ := call["type"].(string)
+		if callType == "" || callType == "function_call" {
+			callType = "function"
+		}
+		arguments, ok := fields["arguments"].(string)
+		if !ok && fields["arguments"] != nil {
+			data, _ := common.Marshal(fields["arguments"])
+			arguments = string(data)
+		}
+		toolCalls = append(toolCalls, dto.ToolCallResponse{
+			ID:   id,
+			Type: callType,
+			Function: dto.FunctionResponse{
+				Name:      name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return toolCalls
+}
+
+// stringifyMetadata coerces metadata values to strings, as Chat only accepts
+// string-to-string metadata. Other values keep their JSON text and nulls are
+// dropped; metadata that is not an object passes through.
+func stringifyMetadata(raw json.RawMessage) json.RawMessage {
+	if common.GetJsonType(raw) != "object" {
+		return raw
+	}
+	var values map[string]json.RawMessage
+	if err := common.Unmarshal(raw, &values); err != nil {
+		return raw
+	}
+	out := make(map[string]string, len(values))
+	for key, value := range values {
+		switch common.GetJsonType(value) {
+		case "string":
+			var s string
+			_ = common.Unmarshal(value, &s)
+			out[key] = s
+		case "null":
+		default:
+			out[key] = strings.TrimSpace(string(value))
+		}
+	}
+	data, err := common.Marshal(out)
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+// functionCallOutputToChat renders a function_call_output for a Chat tool
+// message. An array of content parts becomes tool message content; other
+// structured outputs are kept as JSON and scalars as their string form.
+func functionCallOutputToChat(output any, opts ResponsesToChatOptions) (any, error) {
+	switch v := output.(type) {
+	case []any:
+		if isResponsesContentParts(v) {
+			return toolOutputPartsToChat(v, opts)
+		}
+		if data, err := common.Marshal(v); err == nil {
+			return string(data), nil
+		}
+	case map[string]any:
+		if data, err := common.Marshal(v); err == nil {
+			return string(data), nil
+		}
+	}
+	return common.Interface2String(output), nil
+}
+
+// responsesToolOutputPartTypes are the content part types a tool may return.
+var responsesToolOutputPartTypes = map[string]bool{
+	"input_text":  true,
+	"output_text": true,
+	"input_image": true,
+	"input_file":  true,
+}
+
+func isResponsesContentParts(items []any) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		part, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		if partType, _ := part["type"].(string); !responsesToolOutputPartTypes[partType] {
+			return false
+		}
+	}
+	return true
+}
+
+// toolOutputPartsToChat converts tool output content parts. Text-only output
+// is joined into a string; images and files are only kept when the upstream
+// accepts them in tool messages, otherwise just the text is forwarded.
+func toolOutputPartsToChat(items []any, opts ResponsesToChatOptions) (any, error) {
+	content, err := convertResponsesContentToChat(items)
+	if err != nil {
+		return nil, err
+	}
+	parts, ok := content.([]dto.MediaContent)
+	if !ok {
+		return content, nil
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == dto.ContentTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+	if len(texts) == len(parts) {
+		return strings.Join(texts, "\n"), nil
+	}
+	if opts.ToolMessageImages {
+		return parts, nil
+	}
+	opts.drop("input[].output", "tool message media content is not supported by the upstream")
+	return strings.Join(texts, "\n"), nil
+}
+
+// splitResponsesReasoningParts pulls reasoning_text and summary_text parts
+// out of a content array, returning the remaining content and the reasoning
+// joined by newlines so it can travel as reasoning_content.
+func splitResponsesReasoningParts(content any) (any, string) {
+	parts, ok := content.([]any)
+	if !ok {
+		return content, ""
+	}
+	rest := make([]any, 0, len(parts))
+	var reasoning []string
+	for _, part := range parts {
+		partMap, ok := part.(map[string]any)
+		if ok {
+			switch partMap["type"] {
+			case "reasoning_text", "summary_text":
+				if text, _ := partMap["text"].(string); text != "" {
+					reasoning = append(reasoning, text)
+				}
+				continue
+			}
+		}
+		rest = append(rest, part)
+	}
+	return rest, strings.Join(reasoning, "\n")
+}
+
 // convertResponsesContentToChat converts Responses API content to Chat API content.
-func convertResponsesContentToChat(content any) any {
+func convertResponsesContentToChat(content any) (any, error) {
 	switch v := content.(type) {
 	case string:
-		return v
+		return v, nil
 	case []any:
 		var chatParts []dto.MediaContent
 		for _, part := range v {
@@ -345,35 +1416,34 @@ func convertResponsesContentToChat(content any) any {
 			}
 			partType, _ := partMap["type"].(string)
 			switch partType {
-			case "input_text":
+			case "input_text", "output_text":
 				text, _ := partMap["text"].(string)
 				chatParts = append(chatParts, dto.MediaContent{
 					Type: dto.ContentTypeText,
 					Text: text,
 				})
 			case "input_image":
-				imageURL := partMap["image_url"]
-				switch iu := imageURL.(type) {
-				case string:
-					chatParts = append(chatParts, dto.MediaContent{
-						Type:     dto.ContentTypeImageURL,
-						ImageUrl: &dto.MessageImageUrl{Url: iu},
-					})
-				default:
-					chatParts = append(chatParts, dto.MediaContent{
-						Type:     dto.ContentTypeImageURL,
-						ImageUrl: imageURL,
-					})
+				chatParts = append(chatParts, dto.MediaContent{
+					Type:     dto.ContentTypeImageURL,
+					ImageUrl: convertResponsesInputImage(partMap),
+				})
+			case "input_audio", "audio":
+				audio, err := normalizeResponsesInputAudio(partMap)
+				if err != nil {
+					return nil, err
 				}
-			case "input_audio":
 				chatParts = append(chatParts, dto.MediaContent{
 					Type:       dto.ContentTypeInputAudio,
-					InputAudio: partMap["input_audio"],
+					InputAudio: audio,
 				})
 			case "input_file":
+				file, err := normalizeResponsesInputFile(partMap)
+				if err != nil {
+					return nil, err
+				}
 				chatParts = append(chatParts, dto.MediaContent{
 					Type: dto.ContentTypeFile,
-					File: partMap["file"],
+					File: file,
 				})
 			case "input_video":
 				chatParts = append(chatParts, dto.MediaContent{
@@ -387,12 +1457,91 @@ func convertResponsesContentToChat(content any) any {
 			}
 		}
 		if len(chatParts) > 0 {
-			return chatParts
+			return chatParts, nil
 		}
-		return ""
+		return "", nil
+	case map[string]any:
+		// A single content part sent without the surrounding array.
+		return convertResponsesContentToChat([]any{v})
+	case nil:
+		return "", nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// normalizeResponsesInputFile coerces an input_file part into the Chat file
+// shape {filename, file_id | file_data | file_url}. The fields may sit flat
+// on the part, as the Responses API sends them, or be nested under "file".
+// When several sources are present file_id wins, then file_data.
+func normalizeResponsesInputFile(partMap map[string]any) (*dto.MessageFile, error) {
+	source := partMap
+	if nested, ok := partMap["file"].(map[string]any); ok {
+		source = nested
+	}
+	file := &dto.MessageFile{
+		FileName: common.Interface2String(source["filename"]),
+		FileId:   strings.TrimSpace(common.Interface2String(source["file_id"])),
+	}
+	switch {
+	case file.FileId != "":
+	case strings.TrimSpace(common.Interface2String(source["file_data"])) != "":
+		file.FileData = strings.TrimSpace(common.Interface2String(source["file_data"]))
 	default:
-		return fmt.Sprintf("%v", v)
+		// file_url may be a string or an object with a url field.
+		fileUrl := source["file_url"]
+		if urlMap, ok := fileUrl.(map[string]any); ok {
+			fileUrl = urlMap["url"]
+		}
+		file.FileUrl = strings.TrimSpace(common.Interface2String(fileUrl))
+	}
+	if file.FileId == "" && file.FileData == "" && file.FileUrl == "" {
+		return nil, errors.New(i18n.Translate("svc.input_file_requires_file_id_file_data_or_file_url"))
 	}
+	return file, nil
+}
+
+// inputAudioMimeFormats maps data URI audio MIME types onto the input_audio
+// formats accepted by Chat upstreams.
+var inputAudioMimeFormats = map[string]string{
+	"audio/wav":   "wav",
+	"audio/wave":  "wav",
+	"audio/x-wav": "wav",
+	"audio/mpeg":  "mp3",
+	"audio/mp3":   "mp3",
+}
+
+// normalizeResponsesInputAudio coerces an audio content part into the Chat
+// input_audio shape {data, format}. The payload may be nested under
+// "input_audio" or "audio", or sit flat on the part; a data URI prefix is
+// stripped and used to infer a missing format.
+func normalizeResponsesInputAudio(partMap map[string]any) (*dto.MessageInputAudio, error) {
+	source := partMap
+	for _, key := range []string{"input_audio", "audio"} {
+		if nested, ok := partMap[key].(map[string]any); ok {
+			source = nested
+			break
+		}
+	}
+	data := strings.TrimSpace(common.Interface2String(source["data"]))
+	format := strings.ToLower(strings.TrimSpace(common.Interface2String(source["format"])))
+
+	if strings.HasPrefix(data, "data:") {
+		if header, payload, ok := strings.Cut(data, ","); ok {
+			data = payload
+			mime, _, _ := strings.Cut(strings.TrimPrefix(header, "data:"), ";")
+			if format == "" {
+				format = inputAudioMimeFormats[strings.ToLower(mime)]
+			}
+		}
+	}
+	if data == "" {
+		return nil, errors.New(i18n.Translate("svc.input_audio_data_is_required"))
+	}
+	if format != "wav" && format != "mp3" {
+		return nil, fmt.Errorf(i18n.Translate("svc.unsupported_input_audio_format"), format)
+	}
+	return &dto.MessageInputAudio{Data: data, Format: format}, nil
 }
 
 // convertResponsesTextToResponseFormat converts Responses API text field to Chat API response_format.
@@ -418,74 +1567,421 @@ func convertResponsesTextToResponseFormat(textRaw []byte) *dto.ResponseFormat {
 		return nil
 	}
 
-	rf := &dto.ResponseFormat{Type: formatType}
-	if formatType == "json_schema" {
-		// Reconstruct the json_schema field
-		schemaCopy := make(map[string]any)
-		for k, v := range formatMap {
-			if k == "type" {
+	switch formatType {
+	case "text":
+		// Plain text is the default; some upstreams reject an explicit
+		// response_format for it.
+		return nil
+	case "json_object":
+		return &dto.ResponseFormat{Type: "json_object"}
+	case "json_schema":
+		return &dto.ResponseFormat{
+			Type:       "json_schema",
+			JsonSchema: convertResponsesJsonSchemaFormat(formatMap),
+		}
+	default:
+		return &dto.ResponseFormat{Type: formatType}
+	}
+}
+
+// convertResponsesToolChoice translates an object tool_choice into its Chat
+// form. Forcing a hosted tool becomes "required" when it is the only tool;
+// choices Chat cannot express, such as a specific MCP tool, are rejected
+// instead of being sent upstream as an invalid object.
+func convertResponsesToolChoice(tcMap map[string]any, tools []dto.ToolCallRequest) (any, error) {
+	tcType, _ := tcMap["type"].(string)
+	name, _ := tcMap["name"].(string)
+	switch tcType {
+	case "function":
+		// Responses: {"type": "function", "name": "fn_name"}
+		// Chat:      {"type": "function", "function": {"name": "fn_name"}}
+		if name == "" {
+			return tcMap, nil
+		}
+		return map[string]any{
+			"type":     "function",
+			"function": map[string]any{"name": name},
+		}, nil
+	case "custom":
+		if name == "" {
+			return tcMap, nil
+		}
+		return map[string]any{
+			"type":   "custom",
+			"custom": map[string]any{"name": name},
+		}, nil
+	case "allowed_tools":
+		allowed := map[string]any{"mode": tcMap["mode"]}
+		var chatTools []any
+		rawTools, _ := tcMap["tools"].([]any)
+		for _, rawTool := range rawTools {
+			tool, ok := rawTool.(map[string]any)
+			if !ok {
+				continue
+			}
+			toolType, _ := tool["type"].(string)
+			toolName, _ := tool["name"].(string)
+			if (toolType == "function" || toolType == "custom") && toolName != "" {
+				chatTools = append(chatTools, map[string]any{
+					"type":   toolType,
+					toolType: map[string]any{"name": toolName},
+				})
 				continue
 			}
-			schemaCopy[k] = v
+			chatTools = append(chatTools, tool)
 		}
-		if len(schemaCopy) > 0 {
-			rf.JsonSchema, _ = common.Marshal(schemaCopy)
+		allowed["tools"] = chatTools
+		return map[string]any{
+			"type":          "allowed_tools",
+			"allowed_tools": allowed,
+		}, nil
+	case "file_search", "web_search", "web_search_preview", "code_interpreter", "image_generation", "computer_use_preview":
+		if len(tools) == 1 && tools[0].Type == tcType {
+			return "required", nil
 		}
 	}
-	return rf
+	return nil, fmt.Errorf(i18n.Translate("svc.unsupported_tool_choice"), tcType)
+}
+
+// responsesTextVerbosity returns the verbosity hint of a Responses text
+// object, if any.
+func responsesTextVerbosity(textRaw []byte) json.RawMessage {
+	var textObj struct {
+		Verbosity json.RawMessage `json:"verbosity"`
+	}
+	if err := common.Unmarshal(textRaw, &textObj); err != nil {
+		return nil
+	}
+	if len(textObj.Verbosity) == 0 || common.GetJsonType(textObj.Verbosity) == "null" {
+		return nil
+	}
+	return textObj.Verbosity
+}
+
+// UsesJSONSchemaTextFormat reports whether a Responses request's text field
+// asks for json_schema structured output.
+func UsesJSONSchemaTextFormat(textRaw []byte) bool {
+	format := convertResponsesTextToResponseFormat(textRaw)
+	return format != nil && format.Type == "json_schema"
+}
+
+// defaultJSONSchemaFormatName names a json_schema format sent without one,
+// which Chat upstreams reject.
+const defaultJSONSchemaFormatName = "response"
+
+// defaultJSONSchemaName fills in a missing json_schema name, or fails in
+// strict mode.
+func defaultJSONSchemaName(format *dto.ResponseFormat, strict bool) error {
+	if format == nil || format.Type != "json_schema" || len(format.JsonSchema) == 0 {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := common.Unmarshal(format.JsonSchema, &fields); err != nil {
+		return nil
+	}
+	var name string
+	_ = common.Unmarshal(fields["name"], &name)
+	if strings.TrimSpace(name) != "" {
+		return nil
+	}
+	if strict {
+		return errors.New(i18n.Translate("svc.json_schema_name_is_required"))
+	}
+	fields["name"], _ = common.Marshal(defaultJSONSchemaFormatName)
+	raw, err := common.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	format.JsonSchema = raw
+	return nil
+}
+
+// convertResponsesJsonSchemaFormat assembles Chat's response_format.json_schema
+// object ({name, description, schema, strict}) from the flat Responses
+// text.format object. Fields are read explicitly so strict always ends up next
+// to the schema; a nested "json_schema" object (Chat shape sent to the
+// Responses API) is accepted as a fallback.
+func convertResponsesJsonSchemaFormat(formatMap map[string]any) json.RawMessage {
+	nested, _ := formatMap["json_schema"].(map[string]any)
+	lookup := func(key string) (any, bool) {
+		if v, ok := formatMap[key]; ok {
+			return v, true
+		}
+		v, ok := nested[key]
+		return v, ok
+	}
+
+	jsonSchema := dto.FormatJsonSchema{}
+	if v, ok := lookup("name"); ok {
+		jsonSchema.Name, _ = v.(string)
+	}
+	if v, ok := lookup("description"); ok {
+		jsonSchema.Description, _ = v.(string)
+	}
+	if v, ok := lookup("schema"); ok {
+		jsonSchema.Schema = attachSchemaDefinitions(v, lookup)
+	}
+	if v, ok := lookup("strict"); ok && v != nil {
+		jsonSchema.Strict, _ = common.Marshal(v)
+	}
+
+	raw, err := common.Marshal(jsonSchema)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// orderedChatToolCalls returns the message's tool calls ordered by their
+// "index" field when upstream set one; calls without an index keep their
+// array position as the sort key.
+func orderedChatToolCalls(msg *dto.Message) []dto.ToolCallRequest {
+	toolCalls := msg.ParseToolCalls()
+	var indexes []struct {
+		Index *int `json:"index"`
+	}
+	if len(toolCalls) < 2 || common.Unmarshal(msg.ToolCalls, &indexes) != nil || len(indexes) != len(toolCalls) {
+		return toolCalls
+	}
+	return sortStableByKey(toolCalls, func(i int) int { return lo.FromPtrOr(indexes[i].Index, i) })
+}
+
+// chatAnnotationsToResponses maps Chat message annotations onto output_text
+// annotations; it never returns nil so the field always encodes as a list.
+func chatAnnotationsToResponses(annotations []dto.MessageAnnotation) []interface{} {
+	out := make([]interface{}, 0, len(annotations))
+	for _, annotation := range annotations {
+		item := map[string]any{"type": annotation.Type}
+		if annotation.FileCitation != nil {
+			item["file_id"] = annotation.FileCitation.FileId
+			if annotation.FileCitation.Filename != "" {
+				item["filename"] = annotation.FileCitation.Filename
+			}
+		}
+		if citation := annotation.ContainerFileCitation; citation != nil {
+			item["container_id"] = citation.ContainerId
+			item["file_id"] = citation.FileId
+			if citation.Filename != "" {
+				item["filename"] = citation.Filename
+			}
+			item["start_index"] = citation.StartIndex
+			item["end_index"] = citation.EndIndex
+		}
+		if citation := annotation.URLCitation; citation != nil {
+			item["url"] = citation.Url
+			if citation.Title != "" {
+				item["title"] = citation.Title
+			}
+			item["start_index"] = citation.StartIndex
+			item["end_index"] = citation.EndIndex
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// chatRefusalLogprobs returns the refusal token logprobs of a Chat choice's
+// logprobs object, if any.
+func chatRefusalLogprobs(logprobs json.RawMessage) json.RawMessage {
+	if common.GetJsonType(logprobs) != "object" {
+		return nil
+	}
+	var fields struct {
+		Refusal json.RawMessage `json:"refusal"`
+	}
+	if err := common.Unmarshal(logprobs, &fields); err != nil || common.GetJsonType(fields.Refusal) != "array" {
+		return nil
+	}
+	return fields.Refusal
+}
+
+// isEmptyChatMessage reports whether a choice message carries nothing to
+// convert.
+func isEmptyChatMessage(msg dto.Message) bool {
+	return msg.Content == nil && len(msg.ToolCalls) == 0 && msg.Audio == nil && msg.Refusal == nil &&
+		msg.ReasoningContent == "" && msg.Reasoning == ""
+}
+
+// attachSchemaDefinitions moves "$defs"/"definitions" that a client placed
+// next to "schema" instead of inside it into the schema, so "$ref" pointers
+// such as "#/$defs/Address" still resolve. Definitions already in the schema
+// win; the input map is never modified.
+func attachSchemaDefinitions(schema any, lookup func(key string) (any, bool)) any {
+	schemaMap, ok := schema.(map[string]any)
+	if !ok {
+		return schema
+	}
+	var merged map[string]any
+	for _, key := range []string{"$defs", "definitions"} {
+		defs, ok := lookup(key)
+		if !ok || defs == nil {
+			continue
+		}
+		if _, exists := schemaMap[key]; exists {
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]any, len(schemaMap)+1)
+			for k, v := range schemaMap {
+				merged[k] = v
+			}
+		}
+		merged[key] = defs
+	}
+	if merged == nil {
+		return schema
+	}
+	return merged
+}
+
+// ErrChatResponseNoChoices is returned when a Chat Completions response carries
+// an empty choices array, which has no meaningful Responses representation.
+// The returned error wraps it with a translated message; match it with
+// errors.Is.
+var ErrChatResponseNoChoices = errors.New("chat completions response has no choices")
+
+// translatedError carries a translated message for a sentinel error, so
+// callers still match the sentinel with errors.Is.
+type translatedError struct {
+	message string
+	err     error
+}
+
+func (e *translatedError) Error() string {
+	return e.message
+}
+
+func (e *translatedError) Unwrap() error {
+	return e.err
 }
 
 // ChatCompletionsResponseToResponsesResponse converts a Chat Completions response
 // to a Responses API response. This is the inverse of ResponsesResponseToChatCompletionsResponse.
 func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, model string) (*dto.OpenAIResponsesResponse, error) {
+	return ChatCompletionsResponseToResponsesResponseWithOptions(resp, model, ChatResponseToResponsesOptions{})
+}
+
+// ChatResponseToResponsesOptions configures the Chat to Responses response
+// conversion.
+type ChatResponseToResponsesOptions struct {
+	// IDPrefixes overrides the prefixes of the generated IDs.
+	IDPrefixes IDPrefixes
+	// RequestedModel is the model the client asked for. When set it is the
+	// response's model, in place of the model the upstream served.
+	RequestedModel string
+	// ExposeServedModel reports the served model under "served_model" in the
+	// response metadata when it differs from RequestedModel.
+	ExposeServedModel bool
+	// Usage, when set, replaces the upstream usage, e.g. with usage corrected
+	// by an aggregating proxy. It may use either the Chat or the Responses
+	// field names.
+	Usage *dto.Usage
+}
+
+func ChatCompletionsResponseToResponsesResponseWithOptions(resp *dto.OpenAITextResponse, model string, opts ChatResponseToResponsesOptions) (*dto.OpenAIResponsesResponse, error) {
+	prefixes := opts.IDPrefixes.withDefaults()
 	if resp == nil {
 		return nil, errors.New(i18n.Translate("svc.response_is_nil_c21a"))
 	}
+	if len(resp.Choices) == 0 {
+		return nil, &translatedError{message: i18n.Translate("svc.chat_completions_response_has_no_choices"), err: ErrChatResponseNoChoices}
+	}
 
-	respID := "resp_" + common.GetUUID()
+	respID := prefixes.Response + common.GetUUID()
 	now := int(time.Now().Unix())
 	if model == "" {
 		model = resp.Model
 	}
+	// model is the one the upstream served; report the requested one instead
+	// when the caller routed the request to a different model.
+	servedModel := model
+	if opts.RequestedModel != "" {
+		model = opts.RequestedModel
+	}
 
 	var outputs []dto.ResponsesOutput
-	var usage *dto.Usage
 
-	if len(resp.Choices) > 0 {
-		choice := resp.Choices[0]
+	// Each choice becomes its own message item followed by its tool calls, so
+	// n>1 candidates survive as consecutive output items.
+	for _, choice := range resp.Choices {
+		if choice.Delta != nil && isEmptyChatMessage(choice.Message) {
+			// Stream-shaped choice handed to the non-stream path.
+			choice.Message = *choice.Delta
+		}
+		// Reasoning comes first, as the model produced it before the answer
+		// and the tool calls. Chat carries no encrypted reasoning state, so
+		// the item never has encrypted_content.
+		if reasoningText := lo.CoalesceOrEmpty(choice.Message.ReasoningContent, choice.Message.Reasoning); reasoningText != "" {
+			outputs = append(outputs, dto.ResponsesOutput{
+				Type:    "reasoning",
+				ID:      prefixes.Reasoning + common.GetUUID(),
+				Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: reasoningText}},
+			})
+		}
 
-		// Text content
+		// Text and audio content
+		var content []dto.ResponsesOutputContent
 		if choice.Message.IsStringContent() {
 			text := choice.Message.StringContent()
-			if text != "" {
-				outputs = append(outputs, dto.ResponsesOutput{
-					Type:   "message",
-					ID:     "msg_" + common.GetUUID(),
-					Status: "completed",
-					Role:   "assistant",
-					Content: []dto.ResponsesOutputContent{
-						{
-							Type:        "output_text",
-							Text:        text,
-							Annotations: []interface{}{},
-						},
-					},
+			annotations := chatAnnotationsToResponses(choice.Message.Annotations)
+			// Whitespace-only text next to tool calls is noise, not a message.
+			if strings.TrimSpace(text) != "" || len(annotations) > 0 {
+				content = append(content, dto.ResponsesOutputContent{
+					Type:        "output_text",
+					Text:        text,
+					Annotations: annotations,
 				})
 			}
 		}
+		if refusal := lo.FromPtr(choice.Message.Refusal); refusal != "" {
+			content = append(content, dto.ResponsesOutputContent{
+				Type:     "refusal",
+				Refusal:  refusal,
+				Logprobs: chatRefusalLogprobs(choice.Logprobs),
+			})
+		}
+		if audio := choice.Message.Audio; audio != nil && (audio.Data != "" || audio.Transcript != "") {
+			content = append(content, dto.ResponsesOutputContent{
+				Type:       "output_audio",
+				Data:       audio.Data,
+				Transcript: audio.Transcript,
+			})
+		}
+		if len(content) > 0 {
+			outputs = append(outputs, dto.ResponsesOutput{
+				Type:    "message",
+				ID:      prefixes.Message + common.GetUUID(),
+				Status:  "completed",
+				Role:    "assistant",
+				Content: content,
+			})
+		}
 
 		// Tool calls
-		for _, tc := range choice.Message.ParseToolCalls() {
-			if tc.Type != "" && tc.Type != "function" {
-				continue
-			}
+		for _, tc := range orderedChatToolCalls(&choice.Message) {
 			callID := strings.TrimSpace(tc.ID)
 			if callID == "" {
+				// Some upstreams omit the ID; the client answers with the
+				// generated one, so the call is not lost.
+				callID = prefixes.syntheticCallID()
+			}
+			if tc.Type == "custom" {
+				outputs = append(outputs, dto.ResponsesOutput{
+					Type:   "custom_tool_call",
+					ID:     prefixes.CustomToolCall + common.GetUUID(),
+					Status: "completed",
+					CallId: callID,
+					Name:   tc.Function.Name,
+					Input:  tc.Function.Arguments,
+				})
+				continue
+			}
+			if tc.Type != "" && tc.Type != "function" {
 				continue
 			}
 			outputs = append(outputs, dto.ResponsesOutput{
 				Type:      "function_call",
-				ID:        "fc_" + common.GetUUID(),
+				ID:        prefixes.FunctionCall + common.GetUUID(),
 				Status:    "completed",
 				CallId:    callID,
 				Name:      tc.Function.Name,
@@ -494,52 +1990,118 @@ func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, mo
 		}
 	}
 
-	// Usage conversion
-	usage = &dto.Usage{
-		PromptTokens:     resp.Usage.PromptTokens,
-		CompletionTokens: resp.Usage.CompletionTokens,
-		TotalTokens:      resp.Usage.TotalTokens,
-		InputTokens:      resp.Usage.PromptTokens,
-		OutputTokens:     resp.Usage.CompletionTokens,
-	}
-	if usage.TotalTokens == 0 {
-		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-	}
-	usage.PromptTokensDetails = resp.Usage.PromptTokensDetails
-	usage.CompletionTokenDetails = resp.Usage.CompletionTokenDetails
-	if resp.Usage.PromptTokensDetails.CachedTokens > 0 ||
-		resp.Usage.PromptTokensDetails.ImageTokens > 0 ||
-		resp.Usage.PromptTokensDetails.AudioTokens > 0 {
-		usage.InputTokensDetails = &dto.InputTokenDetails{
-			CachedTokens: resp.Usage.PromptTokensDetails.CachedTokens,
-			ImageTokens:  resp.Usage.PromptTokensDetails.ImageTokens,
-			AudioTokens:  resp.Usage.PromptTokensDetails.AudioTokens,
-		}
+	usageSrc := resp.Usage
+	if opts.Usage != nil {
+		usageSrc = *opts.Usage
 	}
+	usage := chatUsageToResponsesUsage(usageSrc)
 
 	out := &dto.OpenAIResponsesResponse{
-		ID:        respID,
-		Object:    "response",
-		CreatedAt: now,
-		Status:    json.RawMessage(`"completed"`),
-		Model:     model,
-		Output:    outputs,
-		Usage:     usage,
+		ID:                respID,
+		Object:            "response",
+		CreatedAt:         now,
+		Status:            json.RawMessage(`"completed"`),
+		Model:             model,
+		Output:            outputs,
+		Usage:             usage,
+		ServiceTier:       resp.ServiceTier,
+		SystemFingerprint: resp.SystemFingerprint,
+	}
+	if opts.ExposeServedModel && servedModel != "" && servedModel != model {
+		out.Metadata, _ = common.Marshal(map[string]string{"served_model": servedModel})
 	}
 
 	return out, nil
 }
 
+// chatUsageToResponsesUsage fills both the Chat and the Responses usage
+// fields, and their details, from a usage given in either shape.
+func chatUsageToResponsesUsage(src dto.Usage) *dto.Usage {
+	if src.PromptTokens == 0 {
+		src.PromptTokens = src.InputTokens
+	}
+	if src.CompletionTokens == 0 {
+		src.CompletionTokens = src.OutputTokens
+	}
+	if src.InputTokensDetails != nil && src.PromptTokensDetails == (dto.InputTokenDetails{}) {
+		src.PromptTokensDetails = *src.InputTokensDetails
+	}
+	if src.OutputTokensDetails != nil && src.CompletionTokenDetails == (dto.OutputTokenDetails{}) {
+		src.CompletionTokenDetails = *src.OutputTokensDetails
+	}
+
+	usage := &dto.Usage{
+		PromptTokens:     src.PromptTokens,
+		CompletionTokens: src.CompletionTokens,
+		TotalTokens:      src.TotalTokens,
+		InputTokens:      src.PromptTokens,
+		OutputTokens:     src.CompletionTokens,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	usage.PromptTokensDetails = src.PromptTokensDetails
+	usage.CompletionTokenDetails = src.CompletionTokenDetails
+	setResponsesUsageDetails(usage)
+	return usage
+}
+
+// setResponsesUsageDetails always fills the Responses-shaped
+// input_tokens_details and output_tokens_details from the Chat detail blocks,
+// falling back to details the upstream already reported under the Responses
+// names. Reasoning tokens live under output_tokens_details, as in OpenAI's
+// Responses usage.
+func setResponsesUsageDetails(usage *dto.Usage) {
+	input := usage.PromptTokensDetails
+	if input == (dto.InputTokenDetails{}) && usage.InputTokensDetails != nil {
+		input = *usage.InputTokensDetails
+	}
+	usage.InputTokensDetails = &dto.InputTokenDetails{
+		CachedTokens: input.CachedTokens,
+		ImageTokens:  input.ImageTokens,
+		AudioTokens:  input.AudioTokens,
+	}
+	output := usage.CompletionTokenDetails
+	if output == (dto.OutputTokenDetails{}) && usage.OutputTokensDetails != nil {
+		output = *usage.OutputTokensDetails
+	}
+	usage.OutputTokensDetails = &dto.OutputTokenDetails{
+		ReasoningTokens: output.ReasoningTokens,
+		AudioTokens:     output.AudioTokens,
+	}
+}
+
 func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
+	return ExtractOutputTextFromResponsesWithOptions(resp, ExtractOutputTextOptions{})
+}
+
+// ExtractOutputTextOptions tunes ExtractOutputTextFromResponsesWithOptions.
+type ExtractOutputTextOptions struct {
+	// Separator joins the output_text parts. "" reproduces the text byte for
+	// byte, which suits parts that split one paragraph mid-sentence (the
+	// usual streaming case); "\n" keeps separately emitted logical blocks
+	// readable at the cost of fidelity.
+	Separator string
+	// IncludeInProgress also takes text from items whose status is
+	// in_progress or incomplete. By default only completed items (or items
+	// without a status) count, so a response retrieved mid-generation does
+	// not yield partial or duplicated text.
+	IncludeInProgress bool
+}
+
+func ExtractOutputTextFromResponsesWithOptions(resp *dto.OpenAIResponsesResponse, opts ExtractOutputTextOptions) string {
 	if resp == nil || len(resp.Output) == 0 {
 		return ""
 	}
 
-	var sb strings.Builder
+	var parts []string
+	counts := func(out dto.ResponsesOutput) bool {
+		return opts.IncludeInProgress || out.Status == "" || out.Status == "completed"
+	}
 
 	// Prefer assistant message outputs.
 	for _, out := range resp.Output {
-		if out.Type != "message" {
+		if out.Type != "message" || !counts(out) {
 			continue
 		}
 		if out.Role != "" && out.Role != "assistant" {
@@ -547,19 +2109,77 @@ func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 		}
 		for _, c := range out.Content {
 			if c.Type == "output_text" && c.Text != "" {
-				sb.WriteString(c.Text)
+				parts = append(parts, c.Text)
 			}
 		}
 	}
-	if sb.Len() > 0 {
-		return sb.String()
+	if len(parts) > 0 {
+		return strings.Join(parts, opts.Separator)
+	}
+	// Audio-only answers still carry a transcript of what was said.
+	if audio := extractOutputAudioFromResponses(resp); audio != nil && audio.Transcript != "" {
+		return audio.Transcript
 	}
 	for _, out := range resp.Output {
+		if !counts(out) {
+			continue
+		}
 		for _, c := range out.Content {
 			if c.Text != "" {
-				sb.WriteString(c.Text)
+				parts = append(parts, c.Text)
+			}
+		}
+	}
+	return strings.Join(parts, opts.Separator)
+}
+
+// extractOutputAudioFromResponses returns the first output_audio part of the
+// assistant messages, mapped onto a Chat message audio value.
+func extractOutputAudioFromResponses(resp *dto.OpenAIResponsesResponse) *dto.MessageAudio {
+	if resp == nil {
+		return nil
+	}
+	for _, out := range resp.Output {
+		if out.Type != "message" || (out.Role != "" && out.Role != "assistant") {
+			continue
+		}
+		for _, c := range out.Content {
+			if c.Type == "output_audio" {
+				return &dto.MessageAudio{
+					Data:       c.Data,
+					Transcript: c.Transcript,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// extractCodeInterpreterOutputsFromResponses renders code_interpreter_call
+// artifacts (returned when the request includes "code_interpreter_call.outputs")
+// as markdown so they survive the conversion to a Chat message: logs become
+// fenced code blocks and images become markdown image links.
+func extractCodeInterpreterOutputsFromResponses(resp *dto.OpenAIResponsesResponse) string {
+	if resp == nil {
+		return ""
+	}
+	var blocks []string
+	for _, out := range resp.Output {
+		if out.Type != dto.ResponsesOutputTypeCodeInterpreterCall {
+			continue
+		}
+		for _, output := range out.Outputs {
+			switch output.Type {
+			case "logs":
+				if output.Logs != "" {
+					blocks = append(blocks, "```\n"+strings.TrimRight(output.Logs, "\n")+"\n```")
+				}
+			case "image":
+				if output.Url != "" {
+					blocks = append(blocks, "![image]("+output.Url+")")
+				}
 			}
 		}
 	}
-	return sb.String()
+	return strings.Join(blocks, "\n\n")
 }
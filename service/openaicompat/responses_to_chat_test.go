@@ -0,0 +1,1525 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	if err := i18n.Init(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestResponsesResponseToChatKeepsCodeInterpreterOutputs(t *testing.T) {
+	raw := []byte(`{
+		"id":"resp_1",
+		"object":"response",
+		"created_at":1700000000,
+		"status":"completed",
+		"model":"gpt-4.1",
+		"output":[
+			{
+				"type":"code_interpreter_call",
+				"id":"ci_1",
+				"status":"completed",
+				"code":"plt.plot([1,2,3])",
+				"container_id":"cntr_1",
+				"outputs":[
+					{"type":"logs","logs":"plotted\n"},
+					{"type":"image","url":"https://files.example.com/plot.png"}
+				]
+			},
+			{
+				"type":"message",
+				"id":"msg_1",
+				"status":"completed",
+				"role":"assistant",
+				"content":[{"type":"output_text","text":"Here is the plot.","annotations":[]}]
+			}
+		]
+	}`)
+	var resp dto.OpenAIResponsesResponse
+	require.NoError(t, common.Unmarshal(raw, &resp))
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponseWithOptions(&resp, "chatcmpl-1", ResponsesToChatOptions{InlineCodeInterpreterOutputs: true})
+	require.NoError(t, err)
+	require.Len(t, chatResp.Choices, 1)
+
+	content := chatResp.Choices[0].Message.StringContent()
+	require.Equal(t, "```\nplotted\n```\n\n![image](https://files.example.com/plot.png)\n\nHere is the plot.", content)
+
+	// By default the content is only the model's text.
+	chatResp, _, err = ResponsesResponseToChatCompletionsResponse(&resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "Here is the plot.", chatResp.Choices[0].Message.StringContent())
+}
+
+func TestChatToResponsesRequestIncludesCodeInterpreterOutputs(t *testing.T) {
+	req := &dto.GeneralOpenAIRequest{
+		Model:    "gpt-4.1",
+		Messages: []dto.Message{{Role: "user", Content: "plot y=x"}},
+		Tools:    []dto.ToolCallRequest{{Type: "code_interpreter"}},
+	}
+
+	out, err := ChatCompletionsRequestToResponsesRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, []string{responsesIncludeCodeInterpreterOutputs}, parseResponsesInclude(out.Include))
+}
+
+func TestChatResponseWithMultipleChoicesRoundTrip(t *testing.T) {
+	first := dto.Message{Role: "assistant", Content: "first"}
+	second := dto.Message{Role: "assistant", Content: "second"}
+	second.SetToolCalls([]dto.ToolCallResponse{{
+		ID:       "call_1",
+		Type:     "function",
+		Function: dto.FunctionResponse{Name: "lookup", Arguments: `{"q":"x"}`},
+	}})
+	chatResp := &dto.OpenAITextResponse{
+		Model: "gpt-4.1",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Index: 0, Message: first, FinishReason: "stop"},
+			{Index: 1, Message: second, FinishReason: "tool_calls"},
+		},
+	}
+
+	responsesResp, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Len(t, responsesResp.Output, 3)
+	require.Equal(t, "message", responsesResp.Output[0].Type)
+	require.Equal(t, "message", responsesResp.Output[1].Type)
+	require.Equal(t, "function_call", responsesResp.Output[2].Type)
+
+	back, _, err := ResponsesResponseToChatCompletionsResponseWithOptions(responsesResp, "chatcmpl-1", ResponsesToChatOptions{N: 2})
+	require.NoError(t, err)
+	require.Len(t, back.Choices, 2)
+	require.Equal(t, 0, back.Choices[0].Index)
+	require.Equal(t, "first", back.Choices[0].Message.StringContent())
+	require.Equal(t, "stop", back.Choices[0].FinishReason)
+	require.Equal(t, 1, back.Choices[1].Index)
+	require.Equal(t, "second", back.Choices[1].Message.StringContent())
+	require.Equal(t, "tool_calls", back.Choices[1].FinishReason)
+	require.Len(t, back.Choices[1].Message.ParseToolCalls(), 1)
+}
+
+func TestResponsesMessagesAreOneChoiceWithoutN(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Output: []dto.ResponsesOutput{
+			{Type: "message", Role: "assistant", Status: "completed", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Let me check."}}},
+			{Type: "function_call", CallId: "call_1", Name: "lookup", Arguments: json.RawMessage(`"{}"`), Status: "completed"},
+			{Type: "reasoning", ID: "rs_1", Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: "Second thoughts."}}},
+			{Type: "message", Role: "assistant", Status: "completed", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Here it is."}}},
+		},
+	}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Len(t, chatResp.Choices, 1)
+	msg := chatResp.Choices[0].Message
+	require.Contains(t, msg.StringContent(), "Let me check.")
+	require.Contains(t, msg.StringContent(), "Here it is.")
+	require.Equal(t, "Second thoughts.", msg.ReasoningContent)
+	require.Len(t, msg.ParseToolCalls(), 1)
+	require.Equal(t, "tool_calls", chatResp.Choices[0].FinishReason)
+
+	// With n=2 the reasoning item goes with the message that follows it.
+	chatResp, _, err = ResponsesResponseToChatCompletionsResponseWithOptions(resp, "chatcmpl-1", ResponsesToChatOptions{N: 2})
+	require.NoError(t, err)
+	require.Len(t, chatResp.Choices, 2)
+	require.Equal(t, "Let me check.", chatResp.Choices[0].Message.StringContent())
+	require.Empty(t, chatResp.Choices[0].Message.ReasoningContent)
+	require.Len(t, chatResp.Choices[0].Message.ParseToolCalls(), 1)
+	require.Equal(t, "Here it is.", chatResp.Choices[1].Message.StringContent())
+	require.Equal(t, "Second thoughts.", chatResp.Choices[1].Message.ReasoningContent)
+}
+
+func TestResponsesRequestTopLogprobsWithoutInclude(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:       "gpt-4.1",
+		Input:       []byte(`"hello"`),
+		TopLogProbs: common.GetPointer(3),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.NotNil(t, out.LogProbs)
+	require.True(t, *out.LogProbs)
+	require.NotNil(t, out.TopLogProbs)
+	require.Equal(t, 3, *out.TopLogProbs)
+}
+
+func TestResponsesRequestReasoningEffortMapping(t *testing.T) {
+	cases := []struct {
+		model  string
+		effort string
+		want   string
+	}{
+		{model: "gpt-5", effort: "minimal", want: "minimal"},
+		{model: "gpt-5.1", effort: "none", want: "none"},
+		{model: "o3", effort: "none", want: ""},
+	}
+	for _, tc := range cases {
+		req := &dto.OpenAIResponsesRequest{
+			Model:     tc.model,
+			Input:     []byte(`"hello"`),
+			Reasoning: &dto.Reasoning{Effort: tc.effort},
+		}
+		out, err := ResponsesRequestToChatCompletionsRequest(req)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, out.ReasoningEffort, "model=%s effort=%s", tc.model, tc.effort)
+	}
+}
+
+func TestChatResponseWithNoChoices(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{
+		Model:   "gpt-4.1",
+		Choices: []dto.OpenAITextResponseChoice{},
+	}
+
+	out, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.ErrorIs(t, err, ErrChatResponseNoChoices)
+	require.Equal(t, i18n.Translate("svc.chat_completions_response_has_no_choices"), err.Error())
+	require.Nil(t, out)
+}
+
+func TestResponsesTextFormatStrictJsonSchema(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`"extract the person"`),
+		Text: []byte(`{"format":{
+			"type":"json_schema",
+			"name":"person",
+			"strict":true,
+			"schema":{"type":"object","properties":{"name":{"type":"string"}},"required":["name"],"additionalProperties":false}
+		}}`),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.NotNil(t, out.ResponseFormat)
+	require.Equal(t, "json_schema", out.ResponseFormat.Type)
+
+	var jsonSchema map[string]any
+	require.NoError(t, common.Unmarshal(out.ResponseFormat.JsonSchema, &jsonSchema))
+	require.Equal(t, "person", jsonSchema["name"])
+	require.Equal(t, true, jsonSchema["strict"])
+	schema, ok := jsonSchema["schema"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, "object", schema["type"])
+	require.NotContains(t, schema, "strict")
+}
+
+func TestResponsesFunctionToolWithStringParameters(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`"weather?"`),
+		Tools: []byte(`[{
+			"type":"function",
+			"name":"get_weather",
+			"parameters":"{\"type\":\"object\",\"properties\":{\"city\":{\"type\":\"string\"}}}"
+		}]`),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, out.Tools, 1)
+	params, ok := out.Tools[0].Function.Parameters.(map[string]any)
+	require.True(t, ok, "parameters should be an object, got %T", out.Tools[0].Function.Parameters)
+	require.Equal(t, "object", params["type"])
+}
+
+func TestResponsesTextFormatTextAndJsonObject(t *testing.T) {
+	require.Nil(t, convertResponsesTextToResponseFormat([]byte(`{"format":{"type":"text"}}`)))
+
+	rf := convertResponsesTextToResponseFormat([]byte(`{"format":{"type":"json_object","name":"ignored"}}`))
+	require.NotNil(t, rf)
+	require.Equal(t, "json_object", rf.Type)
+	require.Empty(t, rf.JsonSchema)
+}
+
+func TestResponsesFunctionToolInvalidName(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`"weather?"`),
+		Tools: []byte(`[
+			{"type":"web_search_preview"},
+			{"type":"function","name":""}
+		]`),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.Error(t, err)
+	require.Nil(t, out)
+	require.Contains(t, err.Error(), "tools[1]")
+}
+
+func TestResponsesDuplicateFunctionToolNames(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`"weather?"`),
+		Tools: []byte(`[
+			{"type":"function","name":"get_weather","description":"first"},
+			{"type":"function","name":"get_time"},
+			{"type":"function","name":"get_weather","description":"second"}
+		]`),
+	}
+
+	_, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tools[2]")
+
+	var dropped []string
+	out, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		OnDrop: func(path string, reason string) { dropped = append(dropped, path) },
+	})
+	require.NoError(t, err)
+	require.Len(t, out.Tools, 2)
+	require.Equal(t, "get_weather", out.Tools[0].Function.Name)
+	require.Equal(t, "first", out.Tools[0].Function.Description)
+	require.Equal(t, "get_time", out.Tools[1].Function.Name)
+	require.Equal(t, []string{"tools[2]"}, dropped)
+}
+
+func TestResponsesInputImageWithFileIdAndDetail(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`[{"role":"user","content":[
+			{"type":"input_text","text":"describe"},
+			{"type":"input_image","file_id":"file-abc","detail":"low"}
+		]}]`),
+	}
+
+	converted, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	// Round-trip through JSON as the relay does before the request is re-read.
+	raw, err := common.Marshal(converted)
+	require.NoError(t, err)
+	var out dto.GeneralOpenAIRequest
+	require.NoError(t, common.Unmarshal(raw, &out))
+	require.Len(t, out.Messages, 1)
+	parts := out.Messages[0].ParseContent()
+	require.Len(t, parts, 2)
+	img := parts[1].GetImageMedia()
+	require.NotNil(t, img)
+	require.Equal(t, "file-abc", img.FileId)
+	require.Equal(t, "low", img.Detail)
+	require.Empty(t, img.Url)
+
+	back, err := ChatCompletionsRequestToResponsesRequest(&out)
+	require.NoError(t, err)
+	var items []map[string]any
+	require.NoError(t, common.Unmarshal(back.Input, &items))
+	content := items[0]["content"].([]any)
+	imagePart := content[1].(map[string]any)
+	require.Equal(t, "file-abc", imagePart["file_id"])
+	require.Equal(t, "low", imagePart["detail"])
+	require.NotContains(t, imagePart, "image_url")
+}
+
+func TestResponsesCustomToolRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-5",
+		Input: []byte(`[
+			{"role":"user","content":"run it"},
+			{"type":"custom_tool_call","call_id":"call_1","name":"sql","input":"SELECT 1"},
+			{"type":"custom_tool_call_output","call_id":"call_1","output":"1"}
+		]`),
+		Tools: []byte(`[{
+			"type":"custom",
+			"name":"sql",
+			"description":"run a query",
+			"format":{"type":"grammar","syntax":"regex","definition":"^SELECT .+$"}
+		}]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Tools, 1)
+	custom := chatReq.Tools[0].GetCustomTool()
+	require.NotNil(t, custom)
+	require.Equal(t, "sql", custom.Name)
+	require.NotNil(t, custom.Format)
+	require.NotNil(t, custom.Format.Grammar)
+	require.Equal(t, "regex", custom.Format.Grammar.Syntax)
+	require.Equal(t, "^SELECT .+$", custom.Format.Grammar.Definition)
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	var tools []map[string]any
+	require.NoError(t, common.Unmarshal(back.Tools, &tools))
+	require.Len(t, tools, 1)
+	require.Equal(t, "custom", tools[0]["type"])
+	require.Equal(t, map[string]any{"type": "grammar", "syntax": "regex", "definition": "^SELECT .+$"}, tools[0]["format"])
+
+	var items []map[string]any
+	require.NoError(t, common.Unmarshal(back.Input, &items))
+	require.Len(t, items, 4)
+	require.Equal(t, "custom_tool_call", items[2]["type"])
+	require.Equal(t, "SELECT 1", items[2]["input"])
+	require.Equal(t, "custom_tool_call_output", items[3]["type"])
+}
+
+func TestResponsesCustomToolCallOutputToChat(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Model: "gpt-5",
+		Output: []dto.ResponsesOutput{{
+			Type:   "custom_tool_call",
+			ID:     "ctc_1",
+			CallId: "call_1",
+			Name:   "sql",
+			Input:  "SELECT 1",
+		}},
+	}
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "tool_calls", chatResp.Choices[0].FinishReason)
+	toolCalls := chatResp.Choices[0].Message.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "custom", toolCalls[0].Type)
+	require.Equal(t, "sql", toolCalls[0].Function.Name)
+	require.Equal(t, "SELECT 1", toolCalls[0].Function.Arguments)
+}
+
+func TestResponsesMcpOutputItemsToChat(t *testing.T) {
+	raw := []byte(`{
+		"id":"resp_1",
+		"model":"gpt-4.1",
+		"output":[
+			{"type":"mcp_list_tools","id":"mcpl_1","server_label":"deepwiki"},
+			{"type":"mcp_call","id":"mcp_1","server_label":"deepwiki","name":"ask_question","arguments":"{\"q\":\"x\"}","output":"answer"},
+			{"type":"mcp_approval_request","id":"mcpr_1","server_label":"deepwiki","name":"delete_page","arguments":"{}"}
+		]
+	}`)
+	var resp dto.OpenAIResponsesResponse
+	require.NoError(t, common.Unmarshal(raw, &resp))
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(&resp, "chatcmpl-1")
+	require.NoError(t, err)
+	toolCalls := chatResp.Choices[0].Message.ParseToolCalls()
+	require.Len(t, toolCalls, 2)
+	require.Equal(t, "function", toolCalls[0].Type)
+	require.Equal(t, "deepwiki.ask_question", toolCalls[0].Function.Name)
+	require.Equal(t, `{"q":"x"}`, toolCalls[0].Function.Arguments)
+	require.Equal(t, "mcp_approval_request", toolCalls[1].Type)
+	require.Equal(t, "mcpr_1", toolCalls[1].ID)
+	require.Equal(t, "deepwiki.delete_page", toolCalls[1].Function.Name)
+	require.Equal(t, "tool_calls", chatResp.Choices[0].FinishReason)
+}
+
+func TestResponsesMcpToolPassthroughRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`"hi"`),
+		Tools: []byte(`[{"type":"mcp","server_label":"deepwiki","server_url":"https://mcp.deepwiki.com/mcp","require_approval":"never"}]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	var tools []map[string]any
+	require.NoError(t, common.Unmarshal(back.Tools, &tools))
+	require.Equal(t, []map[string]any{{
+		"type":             "mcp",
+		"server_label":     "deepwiki",
+		"server_url":       "https://mcp.deepwiki.com/mcp",
+		"require_approval": "never",
+	}}, tools)
+}
+
+func TestChatResponseAudioTokensToResponsesOutputDetails(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{
+		Model: "gpt-4o-audio-preview",
+		Choices: []dto.OpenAITextResponseChoice{
+			{Index: 0, Message: dto.Message{Role: "assistant", Content: "hi"}, FinishReason: "stop"},
+		},
+		Usage: dto.Usage{
+			PromptTokens:           10,
+			CompletionTokens:       40,
+			TotalTokens:            50,
+			CompletionTokenDetails: dto.OutputTokenDetails{AudioTokens: 32, TextTokens: 8},
+		},
+	}
+
+	out, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.NotNil(t, out.Usage.OutputTokensDetails)
+	require.Equal(t, 32, out.Usage.OutputTokensDetails.AudioTokens)
+
+	raw, err := common.Marshal(out.Usage)
+	require.NoError(t, err)
+	var usage map[string]any
+	require.NoError(t, common.Unmarshal(raw, &usage))
+	details, ok := usage["output_tokens_details"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, float64(32), details["audio_tokens"])
+}
+
+func TestResponsesToolChoiceAutoWithoutTools(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:      "gpt-4.1",
+		Input:      []byte(`"hello"`),
+		ToolChoice: []byte(`"auto"`),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Nil(t, out.ToolChoice)
+
+	req.Tools = []byte(`[{"type":"function","name":"lookup"}]`)
+	out, err = ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, "auto", out.ToolChoice)
+}
+
+func TestResponsesFileSearchCallToAnnotations(t *testing.T) {
+	raw := []byte(`{
+		"id":"resp_1",
+		"model":"gpt-4.1",
+		"output":[
+			{"type":"file_search_call","id":"fs_1","status":"completed","queries":["refund policy"],"results":[
+				{"file_id":"file-1","filename":"policy.pdf","score":0.9,"text":"..."},
+				{"file_id":"file-1","filename":"policy.pdf","score":0.5,"text":"..."},
+				{"file_id":"file-2","filename":"faq.md","score":0.4,"text":"..."}
+			]},
+			{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"Refunds take 5 days.","annotations":[]}]}
+		]
+	}`)
+	var resp dto.OpenAIResponsesResponse
+	require.NoError(t, common.Unmarshal(raw, &resp))
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(&resp, "chatcmpl-1")
+	require.NoError(t, err)
+	msg := chatResp.Choices[0].Message
+	require.Empty(t, msg.ParseToolCalls())
+	require.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+	require.Len(t, msg.Annotations, 2)
+	require.Equal(t, "file_citation", msg.Annotations[0].Type)
+	require.Equal(t, "file-1", msg.Annotations[0].FileCitation.FileId)
+	require.Equal(t, "faq.md", msg.Annotations[1].FileCitation.Filename)
+
+	chatResp, _, err = ResponsesResponseToChatCompletionsResponseWithOptions(&resp, "chatcmpl-1", ResponsesToChatOptions{FileSearchToolCalls: true})
+	require.NoError(t, err)
+	toolCalls := chatResp.Choices[0].Message.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "file_search", toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"queries":["refund policy"]}`, toolCalls[0].Function.Arguments)
+	require.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+}
+
+func TestResponsesMaxInstructionChars(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "gpt-4.1",
+		Input:        []byte(`"hi"`),
+		Instructions: []byte(`"You are a very helpful assistant."`),
+	}
+
+	var dropped []string
+	out, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		MaxInstructionChars: 10,
+		OnDrop:              func(path string, reason string) { dropped = append(dropped, path) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, "system", out.Messages[0].Role)
+	require.Equal(t, "You are a…", out.Messages[0].StringContent())
+	require.Equal(t, []string{"instructions"}, dropped)
+
+	_, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		MaxInstructionChars: 10,
+		Strict:              true,
+	})
+	require.Error(t, err)
+
+	out, err = ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, "You are a very helpful assistant.", out.Messages[0].StringContent())
+}
+
+func TestResponsesAssistantReasoningPartsToReasoningContent(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-5",
+		Input: []byte(`[
+			{"role":"user","content":"2+2?"},
+			{"role":"assistant","content":[
+				{"type":"reasoning_text","text":"Add two and two."},
+				{"type":"summary_text","text":"Simple sum."},
+				{"type":"output_text","text":"4"}
+			]}
+		]`),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 2)
+	assistant := out.Messages[1]
+	require.Equal(t, "Add two and two.\nSimple sum.", assistant.ReasoningContent)
+	parts, ok := assistant.Content.([]dto.MediaContent)
+	require.True(t, ok)
+	require.Len(t, parts, 1)
+	require.Equal(t, dto.ContentTypeText, parts[0].Type)
+	require.Equal(t, "4", parts[0].Text)
+}
+
+func TestResponsesMaxOutputTokensSetMaxTokens(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:           "gpt-4.1",
+		Input:           []byte(`"hi"`),
+		MaxOutputTokens: common.GetPointer(uint(256)),
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, uint(256), *out.MaxCompletionTokens)
+	require.Nil(t, out.MaxTokens)
+
+	out, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{SetMaxTokens: true})
+	require.NoError(t, err)
+	require.Equal(t, uint(256), *out.MaxCompletionTokens)
+	require.NotNil(t, out.MaxTokens)
+	require.Equal(t, uint(256), *out.MaxTokens)
+}
+
+func TestInputAudioNormalization(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4o-audio-preview",
+		Input: []byte(`[{"role":"user","content":[
+			{"type":"input_audio","input_audio":{"data":"AAAA","format":"WAV"}},
+			{"type":"audio","audio":{"data":"data:audio/mpeg;base64,BBBB"}},
+			{"type":"input_audio","data":"CCCC","format":"mp3"}
+		]}]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	raw, err := common.Marshal(chatReq.Messages[0])
+	require.NoError(t, err)
+	var msg dto.Message
+	require.NoError(t, common.Unmarshal(raw, &msg))
+	parts := msg.ParseContent()
+	require.Len(t, parts, 3)
+	expected := []dto.MessageInputAudio{{Data: "AAAA", Format: "wav"}, {Data: "BBBB", Format: "mp3"}, {Data: "CCCC", Format: "mp3"}}
+	for i, part := range parts {
+		require.Equal(t, dto.ContentTypeInputAudio, part.Type)
+		require.Equal(t, &expected[i], part.InputAudio)
+	}
+
+	req.Input = []byte(`[{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"AAAA","format":"flac"}}]}]`)
+	_, err = ResponsesRequestToChatCompletionsRequest(req)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "flac")
+
+	req.Input = []byte(`[{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"AAAA"}}]}]`)
+	_, err = ResponsesRequestToChatCompletionsRequest(req)
+	require.Error(t, err)
+}
+
+func TestServiceTierMapping(t *testing.T) {
+	for _, tier := range []string{"flex", "scale", "priority", "Default"} {
+		req := &dto.OpenAIResponsesRequest{Model: "gpt-4.1", Input: []byte(`"hi"`), ServiceTier: tier}
+		var info ConversionInfo
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true, Info: &info})
+		require.NoError(t, err)
+		require.JSONEq(t, `"`+strings.ToLower(tier)+`"`, string(chatReq.ServiceTier))
+		require.Equal(t, strings.ToLower(tier), info.ServiceTier)
+	}
+
+	req := &dto.OpenAIResponsesRequest{Model: "gpt-4.1", Input: []byte(`"hi"`), ServiceTier: "turbo"}
+	_, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "turbo")
+
+	var dropped []string
+	var info ConversionInfo
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		OnDrop: func(path string, reason string) { dropped = append(dropped, path) },
+		Info:   &info,
+	})
+	require.NoError(t, err)
+	require.Empty(t, chatReq.ServiceTier)
+	require.Empty(t, info.ServiceTier)
+	require.Equal(t, []string{"service_tier"}, dropped)
+}
+
+func TestOutputTextSeparator(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Output: []dto.ResponsesOutput{
+			{
+				Type: "message",
+				Role: "assistant",
+				Content: []dto.ResponsesOutputContent{
+					{Type: "output_text", Text: "First block."},
+					{Type: "output_text", Text: "Second block."},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, "First block.Second block.", ExtractOutputTextFromResponses(resp))
+	require.Equal(t, "First block.\nSecond block.", ExtractOutputTextFromResponsesWithOptions(resp, ExtractOutputTextOptions{Separator: "\n"}))
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponseWithOptions(resp, "chatcmpl-1", ResponsesToChatOptions{OutputTextSeparator: "\n"})
+	require.NoError(t, err)
+	require.Equal(t, "First block.\nSecond block.", chatResp.Choices[0].Message.StringContent())
+}
+
+func TestResponsesOutputSortedByOutputIndex(t *testing.T) {
+	var resp dto.OpenAIResponsesResponse
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"model":"gpt-4.1",
+		"output":[
+			{"type":"function_call","id":"fc_1","call_id":"call_1","name":"lookup","arguments":"{}","output_index":2},
+			{"type":"message","id":"msg_1","role":"assistant","output_index":1,"content":[{"type":"output_text","text":"Checking."}]},
+			{"type":"reasoning","id":"rs_1","output_index":0,"summary":[{"type":"summary_text","text":"think"}]}
+		]
+	}`), &resp))
+
+	sorted := sortResponsesOutputByIndex(resp.Output)
+	require.Equal(t, []string{"rs_1", "msg_1", "fc_1"}, lo.Map(sorted, func(item dto.ResponsesOutput, _ int) string { return item.ID }))
+	require.Equal(t, "fc_1", resp.Output[0].ID)
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(&resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Len(t, chatResp.Choices, 1)
+	msg := chatResp.Choices[0].Message
+	require.Equal(t, "Checking.", msg.StringContent())
+	require.Len(t, msg.ParseToolCalls(), 1)
+	require.Equal(t, "tool_calls", chatResp.Choices[0].FinishReason)
+}
+
+func TestExtractOutputTextSkipsUnfinishedItems(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Status: []byte(`"in_progress"`),
+		Output: []dto.ResponsesOutput{
+			{Type: "message", Status: "completed", Role: "assistant", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Done. "}}},
+			{Type: "message", Status: "in_progress", Role: "assistant", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Still wri"}}},
+		},
+	}
+
+	require.Equal(t, "Done. ", ExtractOutputTextFromResponses(resp))
+	require.Equal(t, "Done. Still wri", ExtractOutputTextFromResponsesWithOptions(resp, ExtractOutputTextOptions{IncludeInProgress: true}))
+
+	// A response that ended truncated keeps its partial text.
+	resp.Status = []byte(`"incomplete"`)
+	resp.Output = resp.Output[1:]
+	resp.Output[0].Status = "incomplete"
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "Still wri", chatResp.Choices[0].Message.StringContent())
+}
+
+func TestResponsesTextFormatJsonSchemaDefs(t *testing.T) {
+	const schema = `{
+		"type":"object",
+		"properties":{"home":{"$ref":"#/$defs/Address"},"work":{"$ref":"#/$defs/Address"}},
+		"$defs":{"Address":{"type":"object","properties":{"city":{"type":"string"},"parent":{"$ref":"#/$defs/Address"}}}}
+	}`
+
+	for name, text := range map[string]string{
+		"schema key": `{"format":{"type":"json_schema","name":"person","schema":` + schema + `}}`,
+		"chat shape": `{"format":{"type":"json_schema","json_schema":{"name":"person","schema":` + schema + `}}}`,
+		"sibling defs": `{"format":{"type":"json_schema","name":"person",
+			"schema":{"type":"object","properties":{"home":{"$ref":"#/$defs/Address"},"work":{"$ref":"#/$defs/Address"}}},
+			"$defs":{"Address":{"type":"object","properties":{"city":{"type":"string"},"parent":{"$ref":"#/$defs/Address"}}}}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := &dto.OpenAIResponsesRequest{Model: "gpt-4.1", Input: []byte(`"who?"`), Text: []byte(text)}
+			out, err := ResponsesRequestToChatCompletionsRequest(req)
+			require.NoError(t, err)
+
+			var jsonSchema struct {
+				Schema json.RawMessage `json:"schema"`
+			}
+			require.NoError(t, common.Unmarshal(out.ResponseFormat.JsonSchema, &jsonSchema))
+			require.JSONEq(t, schema, string(jsonSchema.Schema))
+
+			back, err := ChatCompletionsRequestToResponsesRequest(out)
+			require.NoError(t, err)
+			var backText struct {
+				Format struct {
+					Schema json.RawMessage `json:"schema"`
+				} `json:"format"`
+			}
+			require.NoError(t, common.Unmarshal(back.Text, &backText))
+			require.JSONEq(t, schema, string(backText.Format.Schema))
+		})
+	}
+}
+
+func TestInputMarkdownPreserved(t *testing.T) {
+	const markdown = "# Title\n\nUse `a < b && c > d` here:\n\n```html\n<div class=\"x\">&amp; \"quoted\"</div>\n```\n\n> quote\n- item <b>bold</b>"
+
+	content, err := convertResponsesContentToChat([]any{map[string]any{"type": "input_text", "text": markdown}})
+	require.NoError(t, err)
+	parts, ok := content.([]dto.MediaContent)
+	require.True(t, ok)
+	require.Equal(t, markdown, parts[0].Text)
+
+	content, err = convertResponsesContentToChat(map[string]any{"type": "input_text", "text": markdown})
+	require.NoError(t, err)
+	parts, ok = content.([]dto.MediaContent)
+	require.True(t, ok)
+	require.Equal(t, markdown, parts[0].Text)
+
+	input, err := common.Marshal([]map[string]any{
+		{"role": "user", "content": markdown},
+		{"role": "user", "content": []map[string]any{{"type": "input_text", "text": markdown}}},
+	})
+	require.NoError(t, err)
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(&dto.OpenAIResponsesRequest{Model: "gpt-4.1", Input: input})
+	require.NoError(t, err)
+
+	// What the upstream decodes must equal what the client sent.
+	raw, err := common.Marshal(chatReq)
+	require.NoError(t, err)
+	var decoded dto.GeneralOpenAIRequest
+	require.NoError(t, common.Unmarshal(raw, &decoded))
+	require.Equal(t, markdown, decoded.Messages[0].StringContent())
+	require.Equal(t, markdown, decoded.Messages[1].ParseContent()[0].Text)
+}
+
+func TestNumericReasoningEffort(t *testing.T) {
+	for budget, effort := range map[string]string{"1024": "low", "4096": "medium", "32000": "high"} {
+		var req dto.OpenAIResponsesRequest
+		require.NoError(t, common.Unmarshal([]byte(`{"model":"o3","input":"hi","reasoning":{"effort":`+budget+`,"summary":"auto"}}`), &req))
+		require.Empty(t, req.Reasoning.Effort)
+		require.Equal(t, "auto", req.Reasoning.Summary)
+
+		chatReq, err := ResponsesRequestToChatCompletionsRequest(&req)
+		require.NoError(t, err)
+		require.Equal(t, effort, chatReq.ReasoningEffort, budget)
+	}
+
+	var req dto.OpenAIResponsesRequest
+	require.NoError(t, common.Unmarshal([]byte(`{"model":"o3","input":"hi","reasoning":{"effort":"high"}}`), &req))
+	require.Equal(t, "high", req.Reasoning.Effort)
+	require.Nil(t, req.Reasoning.EffortBudget)
+}
+
+func TestResponsesToolChoiceTypes(t *testing.T) {
+	newReq := func(tools string, toolChoice string) *dto.OpenAIResponsesRequest {
+		return &dto.OpenAIResponsesRequest{
+			Model:      "gpt-4.1",
+			Input:      []byte(`"hi"`),
+			Tools:      []byte(tools),
+			ToolChoice: []byte(toolChoice),
+		}
+	}
+
+	out, err := ResponsesRequestToChatCompletionsRequest(newReq(
+		`[{"type":"custom","name":"run_sql"}]`,
+		`{"type":"custom","name":"run_sql"}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"type": "custom", "custom": map[string]any{"name": "run_sql"}}, out.ToolChoice)
+
+	out, err = ResponsesRequestToChatCompletionsRequest(newReq(
+		`[{"type":"function","name":"a","parameters":{"type":"object"}},{"type":"function","name":"b","parameters":{"type":"object"}}]`,
+		`{"type":"allowed_tools","mode":"required","tools":[{"type":"function","name":"a"}]}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"type": "allowed_tools",
+		"allowed_tools": map[string]any{
+			"mode":  "required",
+			"tools": []any{map[string]any{"type": "function", "function": map[string]any{"name": "a"}}},
+		},
+	}, out.ToolChoice)
+
+	out, err = ResponsesRequestToChatCompletionsRequest(newReq(
+		`[{"type":"file_search","vector_store_ids":["vs_1"]}]`,
+		`{"type":"file_search"}`))
+	require.NoError(t, err)
+	require.Equal(t, "required", out.ToolChoice)
+
+	_, err = ResponsesRequestToChatCompletionsRequest(newReq(
+		`[{"type":"mcp","server_label":"deepwiki","server_url":"https://mcp.deepwiki.com/mcp"}]`,
+		`{"type":"mcp","server_label":"deepwiki","name":"ask_question"}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "mcp")
+}
+
+func TestContainerFileCitationAnnotation(t *testing.T) {
+	var resp dto.OpenAIResponsesResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id": "resp_1",
+		"model": "gpt-4.1",
+		"output": [{
+			"type": "message",
+			"role": "assistant",
+			"content": [{
+				"type": "output_text",
+				"text": "Here is the chart.",
+				"annotations": [
+					{"type": "container_file_citation", "container_id": "cntr_1", "file_id": "cfile_1", "filename": "chart.png", "start_index": 12, "end_index": 17},
+					{"type": "url_citation", "url": "https://example.com", "start_index": 0, "end_index": 4}
+				]
+			}]
+		}]
+	}`, &resp))
+
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(&resp, "chatcmpl_1")
+	require.NoError(t, err)
+	annotations := chatResp.Choices[0].Message.Annotations
+	require.Len(t, annotations, 2)
+	require.Equal(t, "container_file_citation", annotations[0].Type)
+	require.Equal(t, &dto.MessageContainerFileCitation{
+		ContainerId: "cntr_1",
+		FileId:      "cfile_1",
+		Filename:    "chart.png",
+		StartIndex:  12,
+		EndIndex:    17,
+	}, annotations[0].ContainerFileCitation)
+	require.Equal(t, &dto.MessageURLCitation{Url: "https://example.com", StartIndex: 0, EndIndex: 4}, annotations[1].URLCitation)
+
+	roundTrip, err := ChatCompletionsResponseToResponsesResponse(chatResp, "gpt-4.1")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{map[string]any{
+		"type":         "container_file_citation",
+		"container_id": "cntr_1",
+		"file_id":      "cfile_1",
+		"filename":     "chart.png",
+		"start_index":  12,
+		"end_index":    17,
+	}, map[string]any{
+		"type":        "url_citation",
+		"url":         "https://example.com",
+		"start_index": 0,
+		"end_index":   4,
+	}}, roundTrip.Output[0].Content[0].Annotations)
+}
+
+func TestSafetyIdentifierAndUserRoundTrip(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:            "gpt-4.1",
+		Input:            json.RawMessage(`"hi"`),
+		User:             json.RawMessage(`"user-123"`),
+		SafetyIdentifier: json.RawMessage(`"sid-abc"`),
+		PromptCacheKey:   json.RawMessage(`"cache-1"`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.JSONEq(t, `"user-123"`, string(chatReq.User))
+	require.JSONEq(t, `"sid-abc"`, string(chatReq.SafetyIdentifier))
+	require.Equal(t, "cache-1", chatReq.PromptCacheKey)
+	require.Equal(t, "sid-abc", ChatRequestEndUserID(chatReq))
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.JSONEq(t, `"user-123"`, string(back.User))
+	require.JSONEq(t, `"sid-abc"`, string(back.SafetyIdentifier))
+	require.JSONEq(t, `"cache-1"`, string(back.PromptCacheKey))
+
+	chatReq.SafetyIdentifier = nil
+	require.Equal(t, "user-123", ChatRequestEndUserID(chatReq))
+	require.Empty(t, ChatRequestEndUserID(&dto.GeneralOpenAIRequest{}))
+}
+
+func TestStructuredFunctionCallOutput(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"type":"function_call","call_id":"call_1","name":"lookup","arguments":"{}"},
+			{"type":"function_call_output","call_id":"call_1","output":{"city":"Paris","forecast":[{"day":1,"temp":21.5}],"ok":true}},
+			{"type":"function_call","call_id":"call_2","name":"count","arguments":"{}"},
+			{"type":"function_call_output","call_id":"call_2","output":42}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 4)
+	require.Equal(t, "tool", chatReq.Messages[1].Role)
+	require.JSONEq(t, `{"city":"Paris","forecast":[{"day":1,"temp":21.5}],"ok":true}`, chatReq.Messages[1].StringContent())
+	require.Equal(t, "42", chatReq.Messages[3].StringContent())
+}
+
+func TestMetadataValuesCoercedToStrings(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:    "gpt-4.1",
+		Input:    json.RawMessage(`"hi"`),
+		Metadata: json.RawMessage(`{"tenant":"acme","attempt":3,"ratio":0.25,"beta":true,"id":12345678901234567890,"unset":null}`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	var metadata map[string]any
+	require.NoError(t, common.Unmarshal(chatReq.Metadata, &metadata))
+	require.Equal(t, map[string]any{
+		"tenant":  "acme",
+		"attempt": "3",
+		"ratio":   "0.25",
+		"beta":    "true",
+		"id":      "12345678901234567890",
+	}, metadata)
+}
+
+func TestMultiPartFunctionCallOutput(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"type":"function_call","call_id":"call_1","name":"screenshot","arguments":"{}"},
+			{"type":"function_call_output","call_id":"call_1","output":[
+				{"type":"input_text","text":"Captured the page."},
+				{"type":"input_image","image_url":"https://example.com/shot.png"}
+			]},
+			{"type":"function_call","call_id":"call_2","name":"read","arguments":"{}"},
+			{"type":"function_call_output","call_id":"call_2","output":[
+				{"type":"input_text","text":"line 1"},
+				{"type":"input_text","text":"line 2"}
+			]}
+		]`),
+	}
+
+	t.Run("images supported", func(t *testing.T) {
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{ToolMessageImages: true})
+		require.NoError(t, err)
+		raw, err := common.Marshal(chatReq.Messages[1])
+		require.NoError(t, err)
+		var msg dto.Message
+		require.NoError(t, common.Unmarshal(raw, &msg))
+		require.Equal(t, "tool", msg.Role)
+		require.Equal(t, "call_1", msg.ToolCallId)
+		parts := msg.ParseContent()
+		require.Len(t, parts, 2)
+		require.Equal(t, "Captured the page.", parts[0].Text)
+		require.Equal(t, "https://example.com/shot.png", parts[1].GetImageMedia().Url)
+		require.Equal(t, "line 1\nline 2", chatReq.Messages[3].StringContent())
+	})
+
+	t.Run("images unsupported", func(t *testing.T) {
+		var dropped []string
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+			OnDrop: func(path string, reason string) { dropped = append(dropped, path) },
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Captured the page.", chatReq.Messages[1].StringContent())
+		require.Equal(t, []string{"input[].output"}, dropped)
+	})
+}
+
+func TestItemReferenceInput(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"type":"item_reference","id":"msg_stored"},
+			{"role":"user","content":"And now?"}
+		]`),
+	}
+
+	t.Run("resolved", func(t *testing.T) {
+		var gotID string
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+			ItemResolver: func(id string) (json.RawMessage, error) {
+				gotID = id
+				return json.RawMessage(`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Earlier answer"}]}`), nil
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "msg_stored", gotID)
+		require.Len(t, chatReq.Messages, 2)
+		require.Equal(t, "assistant", chatReq.Messages[0].Role)
+		require.Equal(t, "And now?", chatReq.Messages[1].StringContent())
+	})
+
+	t.Run("no resolver", func(t *testing.T) {
+		var dropped []string
+		chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+			OnDrop: func(path string, reason string) { dropped = append(dropped, path) },
+		})
+		require.NoError(t, err)
+		require.Len(t, chatReq.Messages, 1)
+		require.Equal(t, "user", chatReq.Messages[0].Role)
+		require.Equal(t, []string{"input[].item_reference"}, dropped)
+	})
+
+	t.Run("missing item", func(t *testing.T) {
+		_, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+			ItemResolver: func(id string) (json.RawMessage, error) { return nil, nil },
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "msg_stored")
+	})
+}
+
+func TestJsonSchemaFormatWithoutName(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`"hi"`),
+		Text:  json.RawMessage(`{"format":{"type":"json_schema","schema":{"type":"object","properties":{"a":{"type":"string"}}},"strict":true}}`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, "json_schema", chatReq.ResponseFormat.Type)
+	var schema dto.FormatJsonSchema
+	require.NoError(t, common.Unmarshal(chatReq.ResponseFormat.JsonSchema, &schema))
+	require.Equal(t, "response", schema.Name)
+	require.JSONEq(t, `true`, string(schema.Strict))
+	require.Equal(t, map[string]any{"type": "object", "properties": map[string]any{"a": map[string]any{"type": "string"}}}, schema.Schema)
+
+	_, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "name")
+
+	req.Text = json.RawMessage(`{"format":{"type":"json_schema","name":"answer","schema":{"type":"object"}}}`)
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.NoError(t, err)
+	require.NoError(t, common.Unmarshal(chatReq.ResponseFormat.JsonSchema, &schema))
+	require.Equal(t, "answer", schema.Name)
+}
+
+func TestAssistantInputItemWithInlineToolCalls(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"role":"user","content":"Weather in Paris and the time?"},
+			{"role":"assistant","content":"Let me check.","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"type":"function_call","call_id":"call_2","name":"get_time","arguments":{"tz":"CET"}}
+			]},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"},
+			{"type":"function_call_output","call_id":"call_2","output":"12:00"}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 4)
+	assistant := chatReq.Messages[1]
+	require.Equal(t, "assistant", assistant.Role)
+	require.Equal(t, "Let me check.", assistant.StringContent())
+	toolCalls := assistant.ParseToolCalls()
+	require.Len(t, toolCalls, 2)
+	require.Equal(t, "call_1", toolCalls[0].ID)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.Equal(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+	require.Equal(t, "call_2", toolCalls[1].ID)
+	require.Equal(t, "function", toolCalls[1].Type)
+	require.JSONEq(t, `{"tz":"CET"}`, toolCalls[1].Function.Arguments)
+}
+
+func TestMaxToolCallsMapping(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "gpt-4.1",
+		Input:        json.RawMessage(`"hi"`),
+		MaxToolCalls: lo.ToPtr(uint(3)),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{MaxToolCalls: true})
+	require.NoError(t, err)
+	require.Equal(t, uint(3), lo.FromPtr(chatReq.MaxToolCalls))
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.Equal(t, uint(3), lo.FromPtr(back.MaxToolCalls))
+
+	var dropped []string
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		OnDrop: func(path string, reason string) { dropped = append(dropped, path) },
+	})
+	require.NoError(t, err)
+	require.Nil(t, chatReq.MaxToolCalls)
+	require.Equal(t, []string{"max_tool_calls"}, dropped)
+}
+
+func TestInputItemVendorBlockPassthrough(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"type":"message","role":"user","content":"hi","providers":{"acme":{"cache":"ephemeral"}},"x_trace":"t-1"}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Nil(t, chatReq.Messages[0].Extensions)
+
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{PassthroughUnknownFields: true})
+	require.NoError(t, err)
+	msg := chatReq.Messages[0]
+	require.Equal(t, "hi", msg.StringContent())
+	require.Len(t, msg.Extensions, 2)
+	require.JSONEq(t, `{"acme":{"cache":"ephemeral"}}`, string(msg.Extensions["providers"]))
+	require.JSONEq(t, `"t-1"`, string(msg.Extensions["x_trace"]))
+
+	encoded, err := common.Marshal(msg)
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "providers", "extensions are not sent to Chat upstreams")
+
+	back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	var input []map[string]any
+	require.NoError(t, common.Unmarshal(back.Input, &input))
+	require.Equal(t, map[string]any{"acme": map[string]any{"cache": "ephemeral"}}, input[0]["providers"])
+	require.Equal(t, "t-1", input[0]["x_trace"])
+}
+
+func TestEmptyContentArrayMessages(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"role":"user","content":"first"},
+			{"role":"assistant","content":[]},
+			{"role":"system","content":[]},
+			{"role":"user","content":"second"}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 4)
+	require.Equal(t, "", chatReq.Messages[1].StringContent())
+
+	var dropped []string
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		EmptyContentArray: EmptyContentArrayDrop,
+		OnDrop:            func(path string, reason string) { dropped = append(dropped, path) },
+	})
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 2)
+	require.Equal(t, "first", chatReq.Messages[0].StringContent())
+	require.Equal(t, "second", chatReq.Messages[1].StringContent())
+	require.Equal(t, []string{"input[].content", "input[].content"}, dropped)
+
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{EmptyContentArray: EmptyContentArrayUserTurn})
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 3)
+	require.Equal(t, "user", chatReq.Messages[1].Role)
+	require.Equal(t, "", chatReq.Messages[1].StringContent())
+	require.Equal(t, "second", chatReq.Messages[2].StringContent())
+}
+
+func TestCustomToolLarkGrammar(t *testing.T) {
+	definition := "start: expr\nexpr: NUMBER (\"+\" NUMBER)*\n%import common.NUMBER\n%ignore \" \""
+	definitionJSON, err := common.Marshal(definition)
+	require.NoError(t, err)
+
+	for _, format := range []string{
+		`{"type":"grammar","syntax":"lark","definition":` + string(definitionJSON) + `}`,
+		`{"type":"grammar","grammar":{"syntax":"Lark","definition":` + string(definitionJSON) + `}}`,
+	} {
+		req := &dto.OpenAIResponsesRequest{
+			Model: "gpt-5",
+			Input: json.RawMessage(`"add 1 and 2"`),
+			Tools: json.RawMessage(`[{"type":"custom","name":"calc","format":` + format + `}]`),
+		}
+
+		chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+		require.NoError(t, err)
+		custom := chatReq.Tools[0].GetCustomTool()
+		require.NotNil(t, custom)
+		require.Equal(t, &dto.CustomToolGrammar{Syntax: "lark", Definition: definition}, custom.Format.Grammar)
+
+		back, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+		require.NoError(t, err)
+		var tools []map[string]any
+		require.NoError(t, common.Unmarshal(back.Tools, &tools))
+		require.Equal(t, map[string]any{"type": "grammar", "syntax": "lark", "definition": definition}, tools[0]["format"])
+	}
+}
+
+func TestResponsesResponseWithoutUsageEstimate(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Model: "gpt-4.1",
+		Output: []dto.ResponsesOutput{
+			{
+				Type:    "message",
+				Role:    "assistant",
+				Status:  "completed",
+				Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Hello there"}},
+			},
+		},
+	}
+
+	chatResp, usage, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Zero(t, usage.TotalTokens)
+	require.Zero(t, chatResp.Usage.TotalTokens)
+
+	var estimated *dto.OpenAIResponsesResponse
+	chatResp, usage, err = ResponsesResponseToChatCompletionsResponseWithOptions(resp, "chatcmpl-1", ResponsesToChatOptions{
+		EstimateUsage: func(resp *dto.OpenAIResponsesResponse) *dto.Usage {
+			estimated = resp
+			return &dto.Usage{InputTokens: 7, OutputTokens: 2}
+		},
+	})
+	require.NoError(t, err)
+	require.Same(t, resp, estimated)
+	require.Equal(t, 7, usage.PromptTokens)
+	require.Equal(t, 2, usage.CompletionTokens)
+	require.Equal(t, 9, usage.TotalTokens)
+	require.Equal(t, *usage, chatResp.Usage)
+
+	// Upstream usage always wins over the estimate.
+	resp.Usage = &dto.Usage{InputTokens: 3, OutputTokens: 1}
+	_, usage, err = ResponsesResponseToChatCompletionsResponseWithOptions(resp, "chatcmpl-1", ResponsesToChatOptions{
+		EstimateUsage: func(*dto.OpenAIResponsesResponse) *dto.Usage {
+			t.Fatal("estimator called despite upstream usage")
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, usage.TotalTokens)
+}
+
+func TestRequiresActionStatusFinishReason(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Status: json.RawMessage(`"requires_action"`),
+		Output: []dto.ResponsesOutput{},
+	}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Len(t, chatResp.Choices, 1)
+	require.Equal(t, "tool_calls", chatResp.Choices[0].FinishReason)
+
+	resp.Status = json.RawMessage(`"completed"`)
+	chatResp, _, err = ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+
+	state := NewResponsesToChatStreamState("chatcmpl-1", 1700000000, "gpt-4.1")
+	var event dto.ResponsesStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{"type":"response.completed","response":{"id":"resp_1","status":"requires_action"}}`, &event))
+	chunks, oaiErr := state.HandleResponsesEvent(&event)
+	require.Nil(t, oaiErr)
+	require.Equal(t, "tool_calls", *chunks[len(chunks)-1].Choices[0].FinishReason)
+}
+
+func TestInputImageFrames(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[{"role":"user","content":[
+			{"type":"input_text","text":"What happens in this clip?"},
+			{"type":"input_image","detail":"low","frames":[
+				"https://example.com/frame-1.png",
+				{"image_url":"https://example.com/frame-2.png","detail":"high"}
+			]}
+		]}]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{ExpandImageFrames: true})
+	require.NoError(t, err)
+	raw, err := common.Marshal(chatReq.Messages[0])
+	require.NoError(t, err)
+	var msg dto.Message
+	require.NoError(t, common.Unmarshal(raw, &msg))
+	parts := msg.ParseContent()
+	require.Len(t, parts, 3)
+	require.Equal(t, dto.ContentTypeText, parts[0].Type)
+	require.Equal(t, &dto.MessageImageUrl{Url: "https://example.com/frame-1.png", Detail: "low"}, parts[1].GetImageMedia())
+	require.Equal(t, &dto.MessageImageUrl{Url: "https://example.com/frame-2.png", Detail: "high"}, parts[2].GetImageMedia())
+
+	var dropped []string
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{
+		OnDrop: func(path string, reason string) { dropped = append(dropped, path) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"input[].content[].frames"}, dropped)
+	raw, err = common.Marshal(chatReq.Messages[0])
+	require.NoError(t, err)
+	var undropped dto.Message
+	require.NoError(t, common.Unmarshal(raw, &undropped))
+	require.Len(t, undropped.ParseContent(), 2)
+}
+
+func TestReasoningEncryptedContentUnsupported(t *testing.T) {
+	// A Chat client cannot hold encrypted reasoning, so a Responses response
+	// converted to Chat and back has a readable reasoning item only.
+	resp := &dto.OpenAIResponsesResponse{
+		ID:     "resp_1",
+		Model:  "o4-mini",
+		Status: json.RawMessage(`"completed"`),
+		Output: []dto.ResponsesOutput{
+			{Type: "reasoning", ID: "rs_1", Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: "Checked the forecast."}}, EncryptedContent: "gAAAAB-blob"},
+			{Type: "message", ID: "msg_1", Role: "assistant", Status: "completed", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "It is sunny."}}},
+		},
+	}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	raw, err := common.Marshal(chatResp)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "gAAAAB-blob")
+
+	back, err := ChatCompletionsResponseToResponsesResponse(chatResp, "")
+	require.NoError(t, err)
+	require.Equal(t, "reasoning", back.Output[0].Type)
+	require.Empty(t, back.Output[0].EncryptedContent)
+	require.Equal(t, "Checked the forecast.", back.Output[0].Summary[0].Text)
+
+	// Echoed encrypted reasoning is reported dropped, never forwarded.
+	var dropped []string
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(&dto.OpenAIResponsesRequest{
+		Model: "o4-mini",
+		Input: json.RawMessage(`[
+			{"role":"user","content":"Weather?"},
+			{"type":"reasoning","id":"rs_1","summary":[],"encrypted_content":"gAAAAB-blob"},
+			{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{}"},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+		]`),
+	}, ResponsesToChatOptions{OnDrop: func(path string, reason string) { dropped = append(dropped, path) }})
+	require.NoError(t, err)
+	require.Equal(t, []string{"input[].encrypted_content"}, dropped)
+	require.Len(t, chatReq.Messages, 3)
+	raw, err = common.Marshal(chatReq)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "gAAAAB-blob")
+}
+
+func TestEmptyReasoningObject(t *testing.T) {
+	var withEmpty, without dto.OpenAIResponsesRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{"model":"o3","input":"hi","reasoning":{}}`, &withEmpty))
+	require.NoError(t, common.UnmarshalJsonStr(`{"model":"o3","input":"hi"}`, &without))
+	require.NotNil(t, withEmpty.Reasoning)
+	require.Empty(t, withEmpty.Reasoning.Effort)
+	require.Nil(t, withEmpty.Reasoning.EffortBudget)
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(&withEmpty)
+	require.NoError(t, err)
+	require.Empty(t, chatReq.ReasoningEffort)
+
+	// An empty reasoning object neither enables nor disables reasoning: the
+	// upstream sees the same request as without it.
+	expected, err := ResponsesRequestToChatCompletionsRequest(&without)
+	require.NoError(t, err)
+	require.Equal(t, expected, chatReq)
+}
+
+func TestIncompleteMessageFinishReason(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Status:            json.RawMessage(`"incomplete"`),
+		IncompleteDetails: &dto.IncompleteDetails{Reason: "max_output_tokens"},
+		Output: []dto.ResponsesOutput{
+			{
+				Type:    "message",
+				Role:    "assistant",
+				Status:  "incomplete",
+				Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "The forecast for tomor"}},
+			},
+		},
+	}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "length", chatResp.Choices[0].FinishReason)
+	require.Equal(t, "The forecast for tomor", chatResp.Choices[0].Message.StringContent())
+
+	resp.Output[0].Status = "completed"
+	chatResp, _, err = ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+}
+
+func TestMultipleReasoningItemsMerged(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Model: "o4-mini",
+		Output: []dto.ResponsesOutput{
+			{
+				Type:    "reasoning",
+				ID:      "rs_1",
+				Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: "Read the question."}},
+			},
+			{
+				Type: "reasoning",
+				ID:   "rs_2",
+				Summary: []dto.ResponsesReasoningSummaryPart{
+					{Type: "summary_text", Text: "Recall the "},
+					{Type: "summary_text", Text: "boiling point."},
+				},
+			},
+			{
+				Type:    "reasoning",
+				ID:      "rs_3",
+				Content: []dto.ResponsesOutputContent{{Type: "reasoning_text", Text: "Convert to Fahrenheit."}},
+			},
+			{
+				Type:    "message",
+				Role:    "assistant",
+				Status:  "completed",
+				Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Water boils at 212°F."}},
+			},
+		},
+	}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Len(t, chatResp.Choices, 1)
+	msg := chatResp.Choices[0].Message
+	require.Equal(t, "Read the question.\n\nRecall the boiling point.\n\nConvert to Fahrenheit.", msg.ReasoningContent)
+	require.Equal(t, "Water boils at 212°F.", msg.StringContent())
+	require.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+}
+
+func TestFileSearchCallInputReplay(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`[
+			{"role":"user","content":"What is the refund window?"},
+			{"type":"file_search_call","id":"fs_1","status":"completed","queries":["refund window"],
+			 "results":[{"file_id":"file-1","filename":"policy.pdf","score":0.92,"text":"Refunds within 30 days."}]},
+			{"role":"assistant","content":[{"type":"output_text","text":"30 days."}]},
+			{"role":"user","content":"And exchanges?"}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 5)
+
+	call := chatReq.Messages[1]
+	require.Equal(t, "assistant", call.Role)
+	toolCalls := call.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "fs_1", toolCalls[0].ID)
+	require.Equal(t, "file_search", toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"queries":["refund window"]}`, toolCalls[0].Function.Arguments)
+
+	result := chatReq.Messages[2]
+	require.Equal(t, "tool", result.Role)
+	require.Equal(t, "fs_1", result.ToolCallId)
+	require.JSONEq(t, `[{"file_id":"file-1","filename":"policy.pdf","score":0.92,"text":"Refunds within 30 days."}]`, result.StringContent())
+
+	require.Equal(t, "assistant", chatReq.Messages[3].Role)
+	require.Equal(t, "user", chatReq.Messages[4].Role)
+}
+
+func TestWebSearchToolFilters(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: json.RawMessage(`"Latest news?"`),
+		Tools: json.RawMessage(`[
+			{"type":"web_search","search_context_size":"low","filters":{
+				"allowed_domains":["https://www.Reuters.com/world","apnews.com"," apnews.com "],
+				"blocked_domains":"example.com, spam.example"
+			}},
+			{"type":"web_search_preview","blocked_domains":["tabloid.example"],"filters":{"allowed_domains":[]}}
+		]`),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Tools, 2)
+
+	var tool map[string]any
+	require.NoError(t, common.Unmarshal(chatReq.Tools[0].Custom, &tool))
+	require.Equal(t, map[string]any{
+		"type":                "web_search",
+		"search_context_size": "low",
+		"filters": map[string]any{
+			"allowed_domains": []any{"www.reuters.com", "apnews.com"},
+			"blocked_domains": []any{"example.com", "spam.example"},
+		},
+	}, tool)
+
+	tool = nil
+	require.NoError(t, common.Unmarshal(chatReq.Tools[1].Custom, &tool))
+	require.Equal(t, map[string]any{
+		"type":    "web_search_preview",
+		"filters": map[string]any{"blocked_domains": []any{"tabloid.example"}},
+	}, tool)
+}
@@ -0,0 +1,142 @@
+package openaicompat
+
+import (
+	"encoding/json"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponsesResponseToStreamEvents replays a finished Responses response as the
+// events a streaming request receives: response.created, the added, delta and
+// done events of every output item, then the terminal response event. It lets
+// callers serve a stream from a backend that could only answer without one.
+func ResponsesResponseToStreamEvents(resp *dto.OpenAIResponsesResponse) []dto.ResponsesStreamResponse {
+	if resp == nil {
+		return nil
+	}
+	status := resp.GetStatus()
+	if status == "" {
+		status = "completed"
+	}
+
+	start := *resp
+	start.Status = json.RawMessage(`"in_progress"`)
+	start.Output = []dto.ResponsesOutput{}
+	start.Usage = nil
+	events := []dto.ResponsesStreamResponse{
+		{Type: "response.created", ResponseID: resp.ID, Response: &start},
+		{Type: "response.in_progress", ResponseID: resp.ID, Response: &start},
+	}
+
+	for i, item := range resp.Output {
+		added := itemStreamEvent("response.output_item.added", resp.ID, item.ID, i)
+		added.Item = addedResponsesItem(item)
+		events = append(events, added)
+
+		switch item.Type {
+		case "message":
+			events = append(events, messagePartEvents(resp.ID, item, i)...)
+		case "function_call":
+			if args := item.ArgumentsString(); args != "" {
+				delta := itemStreamEvent("response.function_call_arguments.delta", resp.ID, item.ID, i)
+				delta.Delta = args
+				done := itemStreamEvent("response.function_call_arguments.done", resp.ID, item.ID, i)
+				done.Arguments = args
+				events = append(events, delta, done)
+			}
+		case "reasoning":
+			events = append(events, reasoningSummaryEvents(resp.ID, item, i)...)
+		}
+
+		done := itemStreamEvent("response.output_item.done", resp.ID, item.ID, i)
+		doneItem := item
+		done.Item = &doneItem
+		events = append(events, done)
+	}
+
+	events = append(events, dto.ResponsesStreamResponse{
+		Type:       "response." + status,
+		ResponseID: resp.ID,
+		Response:   resp,
+	})
+	return events
+}
+
+func itemStreamEvent(eventType string, responseID string, itemID string, outIndex int) dto.ResponsesStreamResponse {
+	return dto.ResponsesStreamResponse{
+		Type:        eventType,
+		ResponseID:  responseID,
+		ItemID:      itemID,
+		OutputIndex: &outIndex,
+	}
+}
+
+// addedResponsesItem is item as announced by output_item.added: in progress
+// and without the content that the following events stream.
+func addedResponsesItem(item dto.ResponsesOutput) *dto.ResponsesOutput {
+	added := item
+	added.Status = "in_progress"
+	switch item.Type {
+	case "message":
+		added.Content = []dto.ResponsesOutputContent{}
+	case "function_call":
+		added.Arguments = nil
+	case "reasoning":
+		added.Summary = []dto.ResponsesReasoningSummaryPart{}
+	}
+	return &added
+}
+
+func messagePartEvents(responseID string, item dto.ResponsesOutput, outIndex int) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	for j, part := range item.Content {
+		contentIndex := j
+		partEvent := func(eventType string) dto.ResponsesStreamResponse {
+			event := itemStreamEvent(eventType, responseID, item.ID, outIndex)
+			event.ContentIndex = &contentIndex
+			return event
+		}
+
+		added := partEvent("response.content_part.added")
+		added.Part = &dto.ResponsesOutputContent{Type: part.Type, Annotations: []interface{}{}}
+		events = append(events, added)
+		if part.Type == "output_text" {
+			if part.Text != "" {
+				delta := partEvent("response.output_text.delta")
+				delta.Delta = part.Text
+				events = append(events, delta)
+			}
+			textDone := partEvent("response.output_text.done")
+			textDone.Text = part.Text
+			events = append(events, textDone)
+		}
+		done := partEvent("response.content_part.done")
+		donePart := part
+		done.Part = &donePart
+		events = append(events, done)
+	}
+	return events
+}
+
+func reasoningSummaryEvents(responseID string, item dto.ResponsesOutput, outIndex int) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	for j, summary := range item.Summary {
+		summaryIndex := j
+		summaryEvent := func(eventType string) dto.ResponsesStreamResponse {
+			event := itemStreamEvent(eventType, responseID, item.ID, outIndex)
+			event.SummaryIndex = &summaryIndex
+			return event
+		}
+
+		added := summaryEvent("response.reasoning_summary_part.added")
+		added.Part = &dto.ResponsesOutputContent{Type: "summary_text"}
+		delta := summaryEvent("response.reasoning_summary_text.delta")
+		delta.Delta = summary.Text
+		textDone := summaryEvent("response.reasoning_summary_text.done")
+		textDone.Text = summary.Text
+		done := summaryEvent("response.reasoning_summary_part.done")
+		done.Part = &dto.ResponsesOutputContent{Type: "summary_text", Text: summary.Text}
+		events = append(events, added, delta, textDone, done)
+	}
+	return events
+}
@@ -0,0 +1,72 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDowngradeReplaysResponse(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:  "gpt-4.1",
+		Input:  json.RawMessage(`"hi"`),
+		Stream: common.GetPointer(true),
+	}
+
+	var info ConversionInfo
+	chatReq, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{DowngradeStream: true, Info: &info})
+	require.NoError(t, err)
+	require.False(t, *chatReq.Stream)
+	require.Nil(t, chatReq.StreamOptions)
+	require.True(t, info.StreamRequested)
+	require.True(t, info.StreamDowngraded)
+	require.True(t, *req.Stream, "the original request must not be modified")
+
+	info = ConversionInfo{}
+	chatReq, err = ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Info: &info})
+	require.NoError(t, err)
+	require.True(t, *chatReq.Stream)
+	require.NotNil(t, chatReq.StreamOptions)
+	require.True(t, info.StreamRequested)
+	require.False(t, info.StreamDowngraded)
+
+	var chatResp dto.OpenAITextResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id":"chatcmpl-1","model":"gpt-4.1",
+		"choices":[{"index":0,"message":{"role":"assistant","content":"Hello!","tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{\"a\":1}"}}]},"finish_reason":"tool_calls"}],
+		"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}
+	}`, &chatResp))
+	resp, err := ChatCompletionsResponseToResponsesResponse(&chatResp, "gpt-4.1")
+	require.NoError(t, err)
+
+	events := ResponsesResponseToStreamEvents(resp)
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}, types)
+	require.Empty(t, events[0].Response.Output)
+	require.Equal(t, "in_progress", events[0].Response.GetStatus())
+	require.Equal(t, "Hello!", events[4].Delta)
+	require.Equal(t, 1, *events[8].OutputIndex)
+	require.Equal(t, `{"a":1}`, events[10].Arguments)
+	require.Equal(t, resp, events[len(events)-1].Response)
+	require.Equal(t, 7, events[len(events)-1].Response.Usage.TotalTokens)
+}
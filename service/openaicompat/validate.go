@@ -0,0 +1,121 @@
+package openaicompat
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+)
+
+// ValidateChatRequest checks that a (converted) Chat request is
+// self-consistent: every tool message answers a tool call of the assistant
+// turn right before it, every assistant tool call carries an id, and every
+// image_url is one an upstream can fetch (see ValidateImageURL). All problems
+// found are returned joined into one error.
+func ValidateChatRequest(req *dto.GeneralOpenAIRequest) error {
+	if req == nil {
+		return errors.New(i18n.Translate("svc.request_is_nil_827d"))
+	}
+
+	var errs []error
+	// pendingCallIDs holds the tool calls of the latest assistant turn; it
+	// is nil when the previous message cannot be followed by a tool message.
+	var pendingCallIDs map[string]bool
+	for i, msg := range req.Messages {
+		if !msg.IsStringContent() {
+			for j, part := range chatMessageParts(&msg) {
+				if part.Type != dto.ContentTypeImageURL {
+					continue
+				}
+				// An image given only by file_id has no URL to check.
+				if image := part.GetImageMedia(); image != nil && image.Url != "" {
+					if err := ValidateImageURL(image.Url); err != nil {
+						errs = append(errs, fmt.Errorf(i18n.Translate("svc.invalid_message_image_url"), i, j, err))
+					}
+				}
+			}
+		}
+		switch strings.TrimSpace(msg.Role) {
+		case "tool":
+			callID := strings.TrimSpace(msg.ToolCallId)
+			if pendingCallIDs == nil {
+				errs = append(errs, fmt.Errorf(i18n.Translate("svc.tool_message_without_tool_call"), i))
+			} else if !pendingCallIDs[callID] {
+				errs = append(errs, fmt.Errorf(i18n.Translate("svc.tool_message_unknown_call_id"), i, callID))
+			}
+			continue
+		case "assistant":
+			pendingCallIDs = nil
+			toolCalls := msg.ParseToolCalls()
+			if len(toolCalls) == 0 {
+				continue
+			}
+			pendingCallIDs = make(map[string]bool, len(toolCalls))
+			for j, tc := range toolCalls {
+				callID := strings.TrimSpace(tc.ID)
+				if callID == "" {
+					errs = append(errs, fmt.Errorf(i18n.Translate("svc.tool_call_missing_id"), i, j))
+					continue
+				}
+				pendingCallIDs[callID] = true
+			}
+		default:
+			pendingCallIDs = nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// chatMessageParts returns the content parts of a message, whether it was
+// decoded from JSON or built by a conversion as []dto.MediaContent.
+func chatMessageParts(msg *dto.Message) []dto.MediaContent {
+	if parts, ok := msg.Content.([]dto.MediaContent); ok {
+		return parts
+	}
+	return msg.ParseContent()
+}
+
+// supportedImageMimeTypes are the image formats accepted in data: URIs.
+var supportedImageMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ValidateImageURL checks that an image_url is either an https URL with a
+// host or a base64 data: URI of a supported image type. Other schemes, such
+// as file:// or http://, would only fail later with an opaque upstream error.
+func ValidateImageURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if len(raw) >= len("data:") && strings.EqualFold(raw[:len("data:")], "data:") {
+		header, payload, ok := strings.Cut(raw[len("data:"):], ",")
+		if !ok || payload == "" || !strings.HasSuffix(strings.ToLower(header), ";base64") {
+			return errors.New(i18n.Translate("svc.image_url_invalid_data_uri"))
+		}
+		mime, _, _ := strings.Cut(header, ";")
+		if !supportedImageMimeTypes[strings.ToLower(mime)] {
+			return fmt.Errorf(i18n.Translate("svc.image_url_unsupported_mime_type"), mime)
+		}
+		if _, err := base64.StdEncoding.DecodeString(payload); err != nil {
+			return errors.New(i18n.Translate("svc.image_url_invalid_data_uri"))
+		}
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") {
+		scheme := ""
+		if u != nil {
+			scheme = u.Scheme
+		}
+		return fmt.Errorf(i18n.Translate("svc.image_url_unsupported_scheme"), scheme)
+	}
+	if u.Host == "" {
+		return errors.New(i18n.Translate("svc.image_url_missing_host"))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package openaicompat
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateChatRequestOrphanToolMessage(t *testing.T) {
+	req := &dto.GeneralOpenAIRequest{
+		Model: "gpt-4.1",
+		Messages: []dto.Message{
+			{Role: "user", Content: "weather?"},
+			{Role: "tool", ToolCallId: "call_1", Content: "sunny"},
+		},
+	}
+	require.Error(t, ValidateChatRequest(req))
+
+	assistant := dto.Message{Role: "assistant"}
+	assistant.SetToolCalls([]dto.ToolCallRequest{{ID: "call_2", Type: "function", Function: dto.FunctionRequest{Name: "get_weather"}}})
+	req.Messages = []dto.Message{
+		{Role: "user", Content: "weather?"},
+		assistant,
+		{Role: "tool", ToolCallId: "call_1", Content: "sunny"},
+	}
+	err := ValidateChatRequest(req)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "call_1")
+
+	req.Messages[2].ToolCallId = "call_2"
+	require.NoError(t, ValidateChatRequest(req))
+}
+
+func TestValidateChatRequestToolCallMissingID(t *testing.T) {
+	assistant := dto.Message{Role: "assistant"}
+	assistant.SetToolCalls([]dto.ToolCallRequest{
+		{Type: "function", Function: dto.FunctionRequest{Name: "a"}},
+		{Type: "function", Function: dto.FunctionRequest{Name: "b"}},
+	})
+	req := &dto.GeneralOpenAIRequest{
+		Model:    "gpt-4.1",
+		Messages: []dto.Message{{Role: "user", Content: "go"}, assistant},
+	}
+	err := ValidateChatRequest(req)
+	require.Error(t, err)
+	require.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+}
+
+func TestResponsesToChatStrictValidatesMessages(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`[
+			{"role":"user","content":"weather?"},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+		]`),
+	}
+	_, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.Error(t, err)
+
+	_, err = ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+}
+
+func TestValidateImageURL(t *testing.T) {
+	for _, valid := range []string{
+		"https://example.com/cat.png",
+		"data:image/png;base64,iVBORw0KGgo=",
+		"DATA:image/JPEG;base64,/9j/4AAQ",
+	} {
+		require.NoError(t, ValidateImageURL(valid), valid)
+	}
+
+	invalidDataURI := errors.New(i18n.Translate("svc.image_url_invalid_data_uri")).Error()
+	for raw, want := range map[string]string{
+		"file:///etc/passwd":                 fmt.Sprintf(i18n.Translate("svc.image_url_unsupported_scheme"), "file"),
+		"http://example.com/cat.png":         fmt.Sprintf(i18n.Translate("svc.image_url_unsupported_scheme"), "http"),
+		"cat.png":                            fmt.Sprintf(i18n.Translate("svc.image_url_unsupported_scheme"), ""),
+		"https:///cat.png":                   i18n.Translate("svc.image_url_missing_host"),
+		"data:image/svg+xml;base64,PHN2Zz4=": fmt.Sprintf(i18n.Translate("svc.image_url_unsupported_mime_type"), "image/svg+xml"),
+		"data:image/png,rawbytes":            invalidDataURI,
+		"data:image/png;base64":              invalidDataURI,
+		"data:image/png;base64,not base64!":  invalidDataURI,
+	} {
+		err := ValidateImageURL(raw)
+		require.Error(t, err, raw)
+		require.Equal(t, want, err.Error(), raw)
+	}
+}
+
+func TestResponsesToChatStrictValidatesImageURLs(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-4.1",
+		Input: []byte(`[
+			{"role":"user","content":[
+				{"type":"input_text","text":"What is in these?"},
+				{"type":"input_image","image_url":"https://example.com/a.png"},
+				{"type":"input_image","file_id":"file-1"},
+				{"type":"input_image","image_url":"file:///tmp/b.png"}
+			]}
+		]`),
+	}
+	_, err := ResponsesRequestToChatCompletionsRequestWithOptions(req, ResponsesToChatOptions{Strict: true})
+	require.Error(t, err)
+	cause := fmt.Errorf(i18n.Translate("svc.image_url_unsupported_scheme"), "file")
+	require.Equal(t, fmt.Errorf(i18n.Translate("svc.invalid_message_image_url"), 0, 3, cause).Error(), err.Error())
+
+	// Lenient conversion passes the URL through untouched.
+	out, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	parts := chatMessageParts(&out.Messages[0])
+	require.Equal(t, "file:///tmp/b.png", parts[3].GetImageMedia().Url)
+}
@@ -407,3 +407,45 @@ func CountTextToken(text string, model string) int {
 		return EstimateTokenByModel(model, text)
 	}
 }
+
+// ResponsesOutputTokenEstimate 与usage保持一致：OutputTokens包含ReasoningTokens，后者单独列出便于归因
+type ResponsesOutputTokenEstimate struct {
+	OutputTokens    int
+	ReasoningTokens int
+}
+
+// EstimateResponsesOutputTokens 在上游返回usage之前，粗略估算Responses响应的输出token数量
+func EstimateResponsesOutputTokens(resp *dto.OpenAIResponsesResponse) int {
+	return EstimateResponsesOutputTokensDetailed(resp).OutputTokens
+}
+
+// EstimateResponsesOutputTokensDetailed 使用本地tokenizer统计output_text、refusal、工具调用参数及推理摘要
+func EstimateResponsesOutputTokensDetailed(resp *dto.OpenAIResponsesResponse) ResponsesOutputTokenEstimate {
+	var estimate ResponsesOutputTokenEstimate
+	if resp == nil {
+		return estimate
+	}
+	for _, out := range resp.Output {
+		switch out.Type {
+		case "message":
+			for _, c := range out.Content {
+				switch c.Type {
+				case "output_text":
+					estimate.OutputTokens += CountTextToken(c.Text, resp.Model)
+				case "refusal":
+					estimate.OutputTokens += CountTextToken(c.Refusal, resp.Model)
+				}
+			}
+		case "function_call":
+			estimate.OutputTokens += CountTextToken(out.ArgumentsString(), resp.Model)
+		case "custom_tool_call":
+			estimate.OutputTokens += CountTextToken(out.Input, resp.Model)
+		case "reasoning":
+			for _, part := range out.Summary {
+				estimate.ReasoningTokens += CountTextToken(part.Text, resp.Model)
+			}
+		}
+	}
+	estimate.OutputTokens += estimate.ReasoningTokens
+	return estimate
+}
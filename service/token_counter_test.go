@@ -0,0 +1,31 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateResponsesOutputTokens(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{
+		Model: "gpt-4o",
+		Output: []dto.ResponsesOutput{
+			{Type: "reasoning", Summary: []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: "thinking about the weather"}}},
+			{Type: "message", Role: "assistant", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "hello world"}}},
+			{Type: "function_call", CallId: "call_1", Name: "get_weather", Arguments: json.RawMessage(`"{\"city\":\"Paris\"}"`)},
+		},
+	}
+
+	estimate := EstimateResponsesOutputTokensDetailed(resp)
+	reasoning := CountTextToken("thinking about the weather", "gpt-4o")
+	text := CountTextToken("hello world", "gpt-4o")
+	args := CountTextToken(`{"city":"Paris"}`, "gpt-4o")
+
+	require.Equal(t, reasoning, estimate.ReasoningTokens)
+	require.Equal(t, reasoning+text+args, estimate.OutputTokens)
+	require.Equal(t, estimate.OutputTokens, EstimateResponsesOutputTokens(resp))
+	require.Equal(t, estimate, EstimateResponsesOutputTokensDetailed(resp))
+	require.Zero(t, EstimateResponsesOutputTokens(nil))
+}